@@ -0,0 +1,175 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/task"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+type AnnouncementInfo struct {
+	Name string `json:"name"`
+	// cron 表达式，例如 "0 */2 * * *"，与 interval 二选一
+	Cron string `json:"cron"`
+	// 间隔秒数，不小于 60
+	Interval int `json:"interval"`
+	// 公告内容，支持 {online_num}、{time}、{date}、{server_name} 变量
+	Message string `json:"message"`
+	Enabled bool   `json:"enabled"`
+}
+
+// listAnnouncements godoc
+//
+//	@Summary		List Announcements
+//	@Description	List Scheduled Announcements
+//	@Tags			Announcement
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{array}		database.Announcement
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/announcement [get]
+func listAnnouncements(c *gin.Context) {
+	announcements, err := service.ListAnnouncements(serverDB(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, announcements)
+}
+
+// addAnnouncement godoc
+//
+//	@Summary		Add Announcement
+//	@Description	Add a scheduled announcement, run by cron expression or fixed interval
+//	@Tags			Announcement
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			announcement	body		AnnouncementInfo	true	"Announcement"
+//
+//	@Success		200				{object}	database.Announcement
+//	@Failure		400				{object}	ErrorResponse
+//	@Failure		401				{object}	ErrorResponse
+//	@Failure		403				{object}	ErrorResponse
+//	@Router			/api/announcement [post]
+func addAnnouncement(c *gin.Context) {
+	var req AnnouncementInfo
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	announcement := database.Announcement{
+		Id:       uuid.New().String(),
+		Name:     req.Name,
+		Cron:     req.Cron,
+		Interval: req.Interval,
+		Message:  req.Message,
+		Enabled:  req.Enabled,
+	}
+	if err := task.ValidateAnnouncement(announcement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := service.AddAnnouncement(serverDB(c), announcement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := task.ScheduleAnnouncement(currentServer(c), serverDB(c), announcement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, announcement)
+}
+
+// putAnnouncement godoc
+//
+//	@Summary		Put Announcement
+//	@Description	Update a scheduled announcement and reschedule it
+//	@Tags			Announcement
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			announcement_id	path		string				true	"Announcement ID"
+//	@Param			announcement	body		AnnouncementInfo	true	"Announcement"
+//
+//	@Success		200				{object}	database.Announcement
+//	@Failure		400				{object}	ErrorResponse
+//	@Failure		401				{object}	ErrorResponse
+//	@Failure		403				{object}	ErrorResponse
+//	@Failure		404				{object}	EmptyResponse
+//	@Router			/api/announcement/{announcement_id} [put]
+func putAnnouncement(c *gin.Context) {
+	var req AnnouncementInfo
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	announcement, err := service.GetAnnouncement(serverDB(c), c.Param("announcement_id"))
+	if err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	announcement.Name = req.Name
+	announcement.Cron = req.Cron
+	announcement.Interval = req.Interval
+	announcement.Message = req.Message
+	announcement.Enabled = req.Enabled
+	if err = task.ValidateAnnouncement(announcement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err = service.PutAnnouncement(serverDB(c), announcement); err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err = task.ScheduleAnnouncement(currentServer(c), serverDB(c), announcement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, announcement)
+}
+
+// deleteAnnouncement godoc
+//
+//	@Summary		Delete Announcement
+//	@Description	Delete a scheduled announcement
+//	@Tags			Announcement
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			announcement_id	path		string	true	"Announcement ID"
+//
+//	@Success		200				{object}	SuccessResponse
+//	@Failure		400				{object}	ErrorResponse
+//	@Failure		401				{object}	ErrorResponse
+//	@Failure		403				{object}	ErrorResponse
+//	@Failure		404				{object}	EmptyResponse
+//	@Router			/api/announcement/{announcement_id} [delete]
+func deleteAnnouncement(c *gin.Context) {
+	id := c.Param("announcement_id")
+	if err := service.DeleteAnnouncement(serverDB(c), id); err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task.UnscheduleAnnouncement(currentServer(c), id)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
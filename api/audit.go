@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+// 审计日志中请求参数和结果的最大长度
+const auditMaxLength = 2048
+
+// 处理器通过 setAuditTarget 记录操作对象时使用的键
+const auditTargetKey = "audit_target"
+
+// 审计日志列表每页的默认条数和最大条数
+const (
+	auditPageSize    = 100
+	auditMaxPageSize = 1000
+)
+
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.body.Len() < auditMaxLength {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// audit 记录需要审计的管理操作，target 为路由中表示操作对象的参数名
+func audit(action string, target string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 读取请求体后重新写回，供后续处理器使用
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		log := database.AuditLog{
+			Time:     time.Now(),
+			Actor:    auth.GetUsername(c),
+			Role:     string(auth.GetRole(c)),
+			Action:   action,
+			Params:   auditParams(c, body),
+			Status:   writer.Status(),
+			Result:   "success",
+			ClientIp: c.ClientIP(),
+			Server:   c.Param("server_id"),
+		}
+		if target != "" {
+			log.Target = c.Param(target)
+		}
+		if log.Target == "" {
+			log.Target = c.GetString(auditTargetKey)
+		}
+		if log.Status >= http.StatusBadRequest {
+			log.Result = truncate(writer.body.String(), auditMaxLength)
+		}
+		if err := service.AddAuditLog(database.GetDB(), log); err != nil {
+			logger.Errorf("写入审计日志失败: %v\n", err)
+		}
+	}
+}
+
+// setAuditTarget 记录操作对象，用于操作对象不在路由参数而在请求体中的操作
+func setAuditTarget(c *gin.Context, target string) {
+	c.Set(auditTargetKey, target)
+}
+
+// redactParams 递归隐藏请求体中的密码等密钥，包括保存配置时的各项密钥，path 为当前值的路径
+func redactParams(value interface{}, path string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+			if config.IsSecret(itemPath) {
+				v[key] = "***"
+				continue
+			}
+			redactParams(item, itemPath)
+		}
+	case []interface{}:
+		for i, item := range v {
+			redactParams(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func auditParams(c *gin.Context, body []byte) string {
+	params := string(body)
+	// 隐藏 JSON 请求体中的敏感字段
+	var v interface{}
+	if json.Unmarshal(body, &v) == nil {
+		redactParams(v, "")
+		if b, err := json.Marshal(v); err == nil {
+			params = string(b)
+		}
+	}
+	if c.Request.URL.RawQuery != "" {
+		params = c.Request.URL.RawQuery + " " + params
+	}
+	return truncate(params, auditMaxLength)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// listAuditLogs godoc
+//
+//	@Summary		List Audit Logs
+//	@Description	List audit logs of administrative actions, newest first.
+//	@Description	The list is paginated and the total count is returned in the X-Total-Count header, exports contain every matching log
+//	@Tags			Audit
+//	@Accept			json
+//	@Produce		json
+//	@Produce		text/csv
+//	@Security		ApiKeyAuth
+//	@Param			startTime	query		int		false	"Start time in timestamp"
+//	@Param			endTime		query		int		false	"End time in timestamp"
+//	@Param			actor		query		string	false	"Actor username"
+//	@Param			action		query		string	false	"Action"
+//	@Param			target		query		string	false	"Target"
+//	@Param			server		query		string	false	"Server ID, default for the default server"
+//	@Param			page		query		int		false	"Page number, starting from 1"
+//	@Param			pageSize	query		int		false	"Page size, 100 by default and 1000 at most"
+//	@Param			format		query		string	false	"Export format"	enum(json,csv)
+//
+//	@Success		200			{array}		database.AuditLog
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse
+//	@Router			/api/audit [get]
+func listAuditLogs(c *gin.Context) {
+	startTime, endTime, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := service.AuditFilter{
+		StartTime: startTime,
+		EndTime:   endTime,
+		Actor:     c.Query("actor"),
+		Action:    c.Query("action"),
+		Target:    c.Query("target"),
+		Server:    c.Query("server"),
+	}
+	// 导出时返回全部符合条件的日志
+	if c.Query("format") == "" {
+		page, pageSize, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter.Offset = (page - 1) * pageSize
+		filter.Limit = pageSize
+	}
+	logs, total, err := service.ListAuditLogs(database.GetDB(), filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("X-Total-Count", strconv.Itoa(total))
+
+	filename := fmt.Sprintf("audit-%s", time.Now().Format("2006-01-02-15-04-05"))
+	switch c.Query("format") {
+	case "csv":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", filename))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"time", "server", "actor", "role", "action", "target", "params", "status", "result", "client_ip"})
+		for _, log := range logs {
+			w.Write([]string{
+				log.Time.Format(time.RFC3339), log.Server, log.Actor, log.Role, log.Action, log.Target,
+				log.Params, strconv.Itoa(log.Status), log.Result, log.ClientIp,
+			})
+		}
+		w.Flush()
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", filename))
+		c.JSON(http.StatusOK, logs)
+	default:
+		c.JSON(http.StatusOK, logs)
+	}
+}
+
+// parsePage 解析 page 和 pageSize 查询参数
+func parsePage(c *gin.Context) (int, int, error) {
+	page, pageSize := 1, auditPageSize
+	if s := c.Query("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, 0, errors.New("无效的页码")
+		}
+		page = n
+	}
+	if s := c.Query("pageSize"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > auditMaxPageSize {
+			return 0, 0, errors.New("无效的每页条数")
+		}
+		pageSize = n
+	}
+	return page, pageSize, nil
+}
@@ -2,15 +2,15 @@ package api
 
 import (
 	"net/http"
-	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/service"
 )
 
 type LoginInfo struct {
+	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
@@ -35,26 +35,34 @@ func loginHandler(c *gin.Context) {
 		return
 	}
 
-	// 从配置文件中获取正确的密码
-	correctPassword := viper.GetString("web.password")
-	// 检查传入的密码是否正确
-	if loginInfo.Password != correctPassword {
-		// 如果密码不正确，返回401错误码
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
-		return
+	var username string
+	var role auth.Role
+	if loginInfo.Username == "" {
+		// 未提供用户名时使用 web.password 以管理员身份登录
+		if !auth.MatchSecret(loginInfo.Password) {
+			// 如果密码不正确，返回401错误码
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
+			return
+		}
+		role = auth.RoleAdmin
+	} else {
+		// 使用用户账号登录
+		user, err := service.GetUser(database.GetDB(), loginInfo.Username)
+		if err != nil || !auth.CheckPassword(user.PasswordHash, loginInfo.Password) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+			return
+		}
+		username = user.Username
+		role = auth.Role(user.Role)
 	}
 
-	// 创建一个JWT令牌，设置过期时间为24小时后
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
-	})
-	// 签署JWT令牌
-	tokenString, err := token.SignedString(auth.SecretKey)
+	// 创建并签署JWT令牌，过期时间为24小时后
+	tokenString, err := auth.GenerateToken(username, role)
 	// 如果签署失败，返回400错误码
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无法生成令牌"})
 		return
 	}
-	// 返回生成的JWT令牌
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	// 返回生成的JWT令牌及角色
+	c.JSON(http.StatusOK, gin.H{"token": tokenString, "role": role})
 }
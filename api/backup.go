@@ -3,17 +3,22 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/qycnet/palworld-server-tool-main/internal/database"
-	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
 	"github.com/qycnet/palworld-server-tool-main/service"
 )
 
+type RestoreRequest struct {
+	// 服务器运行中时必须为 true，否则服务器的自动保存会覆盖恢复的存档
+	Shutdown bool   `json:"shutdown"`
+	Seconds  int    `json:"seconds"`
+	Message  string `json:"message"`
+}
+
 // listBackups godoc
 //
 //	@Summary		List backups within a specified time range
@@ -62,7 +67,7 @@ func listBackups(c *gin.Context) {
 	}
 
 	// 调用服务方法获取备份列表
-	backups, err := service.ListBackups(database.GetDB(), startTime, endTime)
+	backups, err := service.ListBackups(serverDB(c), startTime, endTime)
 	if err != nil {
 		// 如果获取备份列表失败，返回错误响应
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -92,7 +97,7 @@ func downloadBackup(c *gin.Context) {
 	backupId := c.Param("backup_id")
 
 	// 获取备份信息
-	backup, err := service.GetBackup(database.GetDB(), backupId)
+	backup, err := service.GetBackup(serverDB(c), backupId)
 	if err != nil {
 		// 如果错误类型为没有找到记录，则返回404状态码
 		if err == service.ErrNoRecord {
@@ -104,18 +109,19 @@ func downloadBackup(c *gin.Context) {
 		return
 	}
 
-	// 获取备份目录
-	backupDir, err := tool.GetBackupDir()
+	// 从存储目标读取备份文件
+	r, err := currentServer(c).OpenBackup(backup)
 	if err != nil {
 		// 如果出现错误，则返回500状态码
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	defer r.Close()
 
 	// 设置响应头中的Content-Disposition，以便浏览器下载文件
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", backup.Path))
-	// 发送文件到客户端
-	c.File(filepath.Join(backupDir, backup.Path))
+	c.DataFromReader(http.StatusOK, -1, "application/zip", r, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%s", backup.Path),
+	})
 }
 
 // deleteBackup godoc
@@ -138,7 +144,7 @@ func deleteBackup(c *gin.Context) {
 	var backup database.Backup
 
 	// 调用service.GetBackup函数获取指定ID的备份信息
-	backup, err := service.GetBackup(database.GetDB(), backupId)
+	backup, err := service.GetBackup(serverDB(c), backupId)
 	if err != nil {
 		// 如果错误是service.ErrNoRecord，表示没有找到记录
 		if err == service.ErrNoRecord {
@@ -151,29 +157,117 @@ func deleteBackup(c *gin.Context) {
 		return
 	}
 
-	// 调用service.DeleteBackup函数删除指定ID的备份
-	if err := service.DeleteBackup(database.GetDB(), backupId); err != nil {
+	// 删除备份记录，并从所有存储目标中删除不再被引用的备份文件
+	err = currentServer(c).DeleteBackup(serverDB(c), backup)
+	if err != nil {
 		// 返回400状态码和错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 调用tool.GetBackupDir函数获取备份目录
-	backupDir, err := tool.GetBackupDir()
+	// 返回200状态码和成功信息
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// restoreBackup godoc
+//
+//	@Summary		Restore Backup
+//	@Description	Restore a backup to the game server. The restore is refused while the REST API answers unless shutdown is true,
+//	@Description	docker and k8s servers are stopped during the restore and started again afterwards
+//	@Tags			backup
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			backup_id	path		string			true	"Backup ID"
+//	@Param			restore		body		RestoreRequest	false	"Restore Options"
+//	@Success		200			{object}	SuccessResponse
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		404			{object}	EmptyResponse
+//	@Failure		409			{object}	ErrorResponse
+//	@Failure		500			{object}	ErrorResponse
+//	@Router			/api/backup/{backup_id}/restore [post]
+func restoreBackup(c *gin.Context) {
+	var req RestoreRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	backup, err := service.GetBackup(serverDB(c), c.Param("backup_id"))
 	if err != nil {
-		// 返回500状态码和错误信息
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// 服务器运行中时写入的存档会被下一次自动保存覆盖，必须先关闭服务器
+	_, err = currentServer(c).Info()
+	running := err == nil
+	if running && !req.Shutdown {
+		c.JSON(http.StatusConflict, gin.H{"error": "服务器正在运行, 请设置 shutdown 先关闭服务器再恢复"})
+		return
+	}
+	// 通知玩家并关闭服务器，等待服务器写入存档后退出
+	if running {
+		if req.Seconds <= 0 {
+			req.Seconds = 60
+		}
+		if err := validateMessage(req.Message); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := currentServer(c).Shutdown(req.Seconds, req.Message); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := currentServer(c).WaitForShutdown(time.Duration(req.Seconds)*time.Second + 2*time.Minute); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	// 拼接备份文件的完整路径并尝试删除该文件
-	err = os.Remove(filepath.Join(backupDir, backup.Path))
+	// 恢复前先备份当前存档，以便回退
+	safety, err := currentServer(c).Backup(serverDB(c))
 	if err != nil {
-		// 返回400状态码和错误信息
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复前备份当前存档失败: " + err.Error()})
 		return
 	}
+	logger.Infof("恢复前已备份当前存档 %s\n", safety.Path)
 
-	// 返回200状态码和成功信息
+	if err := currentServer(c).Restore(backup); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	logger.Infof("已恢复备份 %s\n", backup.Path)
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
+
+// verifyBackup godoc
+//
+//	@Summary		Verify Backup
+//	@Description	Verify the checksums of a backup on every storage target and record the result
+//	@Tags			backup
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			backup_id	path		string	true	"Backup ID"
+//	@Success		200			{object}	database.Backup
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		404			{object}	EmptyResponse
+//	@Router			/api/backup/{backup_id}/verify [post]
+func verifyBackup(c *gin.Context) {
+	backup, err := currentServer(c).VerifyBackup(serverDB(c), c.Param("backup_id"))
+	if err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, backup)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+)
+
+// getConfig godoc
+//
+//	@Summary		Get Config
+//	@Description	Get the effective config including defaults and environment variables, secrets are replaced with ******
+//	@Tags			Config
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	map[string]interface{}
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/config [get]
+func getConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, config.Masked())
+}
+
+// putConfig godoc
+//
+//	@Summary		Update Config
+//	@Description	Merge the changes into the config file, validate and reload it. Objects are merged, lists are replaced,
+//	@Description	null removes a key so its default applies, and secrets sent as ****** keep their current value.
+//	@Description	web.port, web.tls and supervisor changes take effect after a restart, environment variables take precedence over the file.
+//	@Tags			Config
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			config	body		map[string]interface{}	true	"Changes"
+//
+//	@Success		200		{object}	map[string]interface{}
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Router			/api/config [put]
+func putConfig(c *gin.Context) {
+	var changes map[string]interface{}
+	if err := c.ShouldBindJSON(&changes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := config.Update(changes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, config.Masked())
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getContainerUsage godoc
+//
+//	@Summary		Get Container Usage
+//	@Description	Get CPU and memory usage of the game server container configured in save.path
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	source.ResourceUsage
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/server/container/usage [get]
+func getContainerUsage(c *gin.Context) {
+	usage, err := currentServer(c).ContainerUsage()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// startContainer godoc
+//
+//	@Summary		Start Container
+//	@Description	Start the game server docker container
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	SuccessResponse
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/server/container/start [post]
+func startContainer(c *gin.Context) {
+	if err := currentServer(c).StartContainer(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// stopContainer godoc
+//
+//	@Summary		Stop Container
+//	@Description	Save the world and stop the game server docker container
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	SuccessResponse
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/server/container/stop [post]
+func stopContainer(c *gin.Context) {
+	if err := currentServer(c).StopContainer(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// restartContainer godoc
+//
+//	@Summary		Restart Container
+//	@Description	Save the world and restart the game server docker container, or delete the pod so its controller recreates it
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	SuccessResponse
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/server/container/restart [post]
+func restartContainer(c *gin.Context) {
+	if err := currentServer(c).RestartContainer(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// getContainerLogs godoc
+//
+//	@Summary		Get Container Logs
+//	@Description	Stream logs of the game server container as plain text
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		plain
+//	@Security		ApiKeyAuth
+//	@Param			tail	query		int		false	"Number of lines from the end, 0 for all"	default(100)
+//	@Param			follow	query		bool	false	"Keep streaming new logs"
+//
+//	@Success		200		{string}	string
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Router			/api/server/container/logs [get]
+func getContainerLogs(c *gin.Context) {
+	tail, err := strconv.Atoi(c.DefaultQuery("tail", "100"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tail"})
+		return
+	}
+	follow := c.Query("follow") == "true"
+
+	logs, err := currentServer(c).ContainerLogs(c.Request.Context(), tail, follow)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer logs.Close()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, werr := c.Writer.Write(buf[:n]); werr != nil {
+				return
+			}
+			// 持续输出时每次读取后立即发送给客户端
+			c.Writer.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
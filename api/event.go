@@ -0,0 +1,108 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/event"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
+)
+
+// 没有事件时定期发送注释，防止代理断开空闲连接
+const eventHeartbeat = 15 * time.Second
+
+// visibleEvent 按 visibility.online_players 配置过滤在线玩家相关的事件
+func visibleEvent(e event.Event, role auth.Role) (event.Event, bool) {
+	if e.Type != event.EventPlayers && e.Type != webhook.EventPlayerJoin && e.Type != webhook.EventPlayerLeave {
+		return e, true
+	}
+	allowed, redact := resourceAccess("online_players", role)
+	if !allowed {
+		return e, false
+	}
+	if !redact {
+		return e, true
+	}
+	switch data := e.Data.(type) {
+	case []database.OnlinePlayer:
+		e.Data = redactOnlinePlayers(data)
+	case map[string]interface{}:
+		if redactFields()["steam_id"] {
+			redacted := make(map[string]interface{}, len(data))
+			for k, v := range data {
+				if k != "steam_id" {
+					redacted[k] = v
+				}
+			}
+			e.Data = redacted
+		}
+	}
+	return e, true
+}
+
+// streamEvents godoc
+//
+//	@Summary		Stream Events
+//	@Description	Push player, metrics, sync and backup events as Server-Sent Events. Anonymous clients only receive player join/leave, online players, metrics, sync and server status events; pass a login token in the Authorization header or the token parameter to receive the rest
+//	@Tags			Server
+//	@Produce		text/event-stream
+//	@Param			events	query		string	false	"Comma separated event types to receive, all by default"
+//	@Param			token	query		string	false	"Login token"
+//
+//	@Success		200		{object}	event.Event
+//	@Failure		401		{object}	ErrorResponse
+//	@Router			/api/events [get]
+func streamEvents(c *gin.Context) {
+	// EventSource 无法设置请求头，也可以通过 token 参数传递令牌
+	role, ok := optionalRole(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权 - 令牌无效"})
+		return
+	}
+	var types map[string]bool
+	if events := c.Query("events"); events != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(events, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	events, unsubscribe := event.Subscribe(role)
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// 禁止 nginx 缓冲事件流
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("connected", gin.H{"time": time.Now()})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			io.WriteString(w, ": ping\n\n")
+			return true
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			if types != nil && !types[e.Type] {
+				return true
+			}
+			if e, ok = visibleEvent(e, role); ok {
+				c.SSEvent(e.Type, e)
+			}
+			return true
+		}
+	})
+}
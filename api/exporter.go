@@ -0,0 +1,41 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/exporter"
+	"github.com/spf13/viper"
+)
+
+// metricsAuth 配置了 web.metrics_token 时要求请求携带该令牌
+func metricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := viper.GetString("web.metrics_token")
+		if token == "" {
+			c.Next()
+			return
+		}
+		got := c.GetHeader("Authorization")
+		if subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// getMetrics godoc
+//
+//	@Summary		Prometheus Metrics
+//	@Description	Export server, player, save and scheduler metrics in Prometheus text format, requires web.metrics_token as a Bearer token when configured
+//	@Tags			Server
+//	@Produce		plain
+//
+//	@Success		200	{string}	string
+//	@Failure		401	{object}	ErrorResponse
+//	@Router			/metrics [get]
+func getMetrics(c *gin.Context) {
+	exporter.Handler().ServeHTTP(c.Writer, c.Request)
+}
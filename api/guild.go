@@ -12,7 +12,7 @@ import (
 // putGuilds godoc
 //
 //	@Summary		Put Guilds
-//	@Description	Put Guilds Only For SavSync
+//	@Description	Put Guilds Only For SavSync, guilds are upserted without archiving missing guilds or recording guild history
 //	@Tags			Guild
 //	@Accept			json
 //	@Produce		json
@@ -37,7 +37,7 @@ func putGuilds(c *gin.Context) {
 	}
 
 	// 将公会信息保存到数据库中
-	if err := service.PutGuilds(database.GetDB(), guilds); err != nil {
+	if err := service.PutGuilds(serverDB(c), guilds); err != nil {
 		// 如果保存失败，返回400 Bad Request错误
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -56,10 +56,12 @@ func putGuilds(c *gin.Context) {
 //	@Produce		json
 //	@Success		200	{object}	[]database.Guild
 //	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
 //	@Router			/api/guild [get]
 func listGuilds(c *gin.Context) {
 	// 从数据库中获取公会列表
-	guilds, err := service.ListGuilds(database.GetDB())
+	guilds, err := service.ListGuilds(serverDB(c))
 	if err != nil {
 		// 如果获取公会列表失败，则返回错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -72,6 +74,12 @@ func listGuilds(c *gin.Context) {
 		return guilds[i].BaseCampLevel > guilds[j].BaseCampLevel
 	})
 
+	if shouldRedact(c) {
+		fields := redactFields()
+		for i := range guilds {
+			redactGuild(&guilds[i], fields)
+		}
+	}
 	// 返回排序后的公会列表
 	c.JSON(http.StatusOK, guilds)
 }
@@ -86,11 +94,13 @@ func listGuilds(c *gin.Context) {
 //	@Param			admin_player_uid	path		string	true	"Admin Player UID"
 //	@Success		200					{object}	database.Guild
 //	@Failure		400					{object}	ErrorResponse
+//	@Failure		401					{object}	ErrorResponse
+//	@Failure		403					{object}	ErrorResponse
 //	@Failure		404					{object}	EmptyResponse
 //	@Router			/api/guild/{admin_player_uid} [get]
 func getGuild(c *gin.Context) {
 	// 从数据库中获取公会信息
-	guild, err := service.GetGuild(database.GetDB(), c.Param("admin_player_uid"))
+	guild, err := service.GetGuild(serverDB(c), c.Param("admin_player_uid"))
 	if err != nil {
 		// 如果错误为没有记录的错误
 		if err == service.ErrNoRecord {
@@ -102,6 +112,134 @@ func getGuild(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if shouldRedact(c) {
+		redactGuild(&guild, redactFields())
+	}
 	// 返回200状态码和公会信息
 	c.JSON(http.StatusOK, guild)
 }
+
+// getGuildStats godoc
+//
+//	@Summary		Get Guild Stats
+//	@Description	Get total member levels, pal count and base camp count of a guild
+//	@Tags			Guild
+//	@Accept			json
+//	@Produce		json
+//	@Param			admin_player_uid	path		string	true	"Admin Player UID"
+//	@Success		200					{object}	database.GuildStats
+//	@Failure		400					{object}	ErrorResponse
+//	@Failure		401					{object}	ErrorResponse
+//	@Failure		403					{object}	ErrorResponse
+//	@Failure		404					{object}	EmptyResponse
+//	@Router			/api/guild/{admin_player_uid}/stats [get]
+func getGuildStats(c *gin.Context) {
+	db := serverDB(c)
+	guild, err := service.GetGuild(db, c.Param("admin_player_uid"))
+	if err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stats, err := service.GetGuildStats(db, guild)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// listGuildHistory godoc
+//
+//	@Summary		List Guild History
+//	@Description	List guild changes found on sav sync, newest first: create, disband, join, leave and admin_change
+//	@Tags			Guild
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			startTime	query		int	false	"Start time in timestamp"
+//	@Param			endTime		query		int	false	"End time in timestamp"
+//
+//	@Success		200			{array}		database.GuildEvent
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Router			/api/guild/history [get]
+func listGuildHistory(c *gin.Context) {
+	startTime, endTime, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, err := service.ListGuildEvents(serverDB(c), service.GuildEventFilter{StartTime: startTime, EndTime: endTime})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// getGuildHistory godoc
+//
+//	@Summary		Get Guild History
+//	@Description	List changes of a guild, newest first. Disbanded guilds are matched by their last admin
+//	@Tags			Guild
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			admin_player_uid	path		string	true	"Admin Player UID"
+//	@Param			startTime			query		int		false	"Start time in timestamp"
+//	@Param			endTime				query		int		false	"End time in timestamp"
+//
+//	@Success		200					{array}		database.GuildEvent
+//	@Failure		400					{object}	ErrorResponse
+//	@Failure		401					{object}	ErrorResponse
+//	@Router			/api/guild/{admin_player_uid}/history [get]
+func getGuildHistory(c *gin.Context) {
+	startTime, endTime, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	db := serverDB(c)
+	filter := service.GuildEventFilter{StartTime: startTime, EndTime: endTime, AdminPlayerUid: c.Param("admin_player_uid")}
+	// 公会仍然存在时按 GroupId 匹配，包括更换管理员之前的记录
+	guild, err := service.GetGuild(db, filter.AdminPlayerUid)
+	if err == nil {
+		filter.GroupId = guild.GroupId
+		filter.AdminPlayerUid = guild.AdminPlayerUid
+	} else if err != service.ErrNoRecord {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, err := service.ListGuildEvents(db, filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// listArchivedGuilds godoc
+//
+//	@Summary		List Archived Guilds
+//	@Description	List disbanded guilds with their data from the last sav sync before disbanding, newest first
+//	@Tags			Guild
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{array}		database.ArchivedGuild
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Router			/api/guild/archive [get]
+func listArchivedGuilds(c *gin.Context) {
+	guilds, err := service.ListArchivedGuilds(serverDB(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, guilds)
+}
@@ -0,0 +1,337 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/task"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+type GlobalBanInfo struct {
+	SteamId string `json:"steam_id" binding:"required"`
+	// 为空时使用玩家最近在线的昵称
+	Nickname string `json:"nickname"`
+	Reason   string `json:"reason"`
+	// 封禁时长，单位秒，为 0 时永久封禁
+	Duration int64 `json:"duration"`
+}
+
+type GlobalBanResponse struct {
+	Success bool `json:"success"`
+	// 封禁或解除封禁失败的服务器，封禁时玩家上线会重新封禁，解除时在同步时重试
+	FailedServers []string `json:"failed_servers"`
+}
+
+// normalizeSteamId 去掉 SteamId 的 steam_ 前缀，与玩家记录中的格式一致
+func normalizeSteamId(steamId string) string {
+	return strings.TrimPrefix(strings.TrimSpace(steamId), "steam_")
+}
+
+// listAllIdentities 按 SteamId 关联所有服务器中的玩家记录，并标记全局白名单和全局封禁
+func listAllIdentities() ([]database.Identity, error) {
+	servers := tool.Servers()
+	players := make([]service.ServerPlayers, 0, len(servers))
+	for _, server := range servers {
+		list, err := service.ListPlayers(server.DB())
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, service.ServerPlayers{Server: server.Id, Players: list})
+	}
+	identities := service.LinkIdentities(players)
+
+	db := database.GetDB()
+	whitelist, err := service.ListGlobalWhitelist(db)
+	if err != nil {
+		return nil, err
+	}
+	bans, err := service.ListGlobalBans(db)
+	if err != nil {
+		return nil, err
+	}
+	whitelisted := make(map[string]bool, len(whitelist))
+	for _, player := range whitelist {
+		whitelisted[player.SteamID] = true
+	}
+	banned := make(map[string]bool, len(bans))
+	for _, ban := range bans {
+		// 已解除但仍有服务器等待解除的封禁不再生效
+		banned[ban.SteamId] = len(ban.PendingUnban) == 0
+	}
+	for i := range identities {
+		identities[i].Whitelisted = whitelisted[identities[i].SteamId]
+		identities[i].Banned = banned[identities[i].SteamId]
+	}
+	return identities, nil
+}
+
+// listIdentities godoc
+//
+//	@Summary		List Identities
+//	@Description	List players of all servers linked by SteamID
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	[]database.Identity
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Router			/api/identity [get]
+func listIdentities(c *gin.Context) {
+	identities, err := listAllIdentities()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+// getIdentity godoc
+//
+//	@Summary		Get Identity
+//	@Description	Get the player records of all servers for a SteamID
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			steam_id	path		string	true	"Steam ID"
+//
+//	@Success		200			{object}	database.Identity
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		404			{object}	ErrorResponse
+//	@Router			/api/identity/{steam_id} [get]
+func getIdentity(c *gin.Context) {
+	identities, err := listAllIdentities()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	steamId := normalizeSteamId(c.Param("steam_id"))
+	for _, identity := range identities {
+		if identity.SteamId == steamId {
+			c.JSON(http.StatusOK, identity)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "未找到玩家"})
+}
+
+// listGlobalWhitelist godoc
+//
+//	@Summary		List Global White List
+//	@Description	List the white list shared by all servers
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	[]database.PlayerW
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Router			/api/global/whitelist [get]
+func listGlobalWhitelist(c *gin.Context) {
+	players, err := service.ListGlobalWhitelist(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, players)
+}
+
+// addGlobalWhitelist godoc
+//
+//	@Summary		Add Global White List
+//	@Description	Add a player to the white list of all servers, steam_id is required
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			player	body		database.PlayerW	true	"Player"
+//
+//	@Success		200		{object}	SuccessResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Router			/api/global/whitelist [post]
+func addGlobalWhitelist(c *gin.Context) {
+	var player database.PlayerW
+	if err := c.ShouldBindJSON(&player); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	player.SteamID = normalizeSteamId(player.SteamID)
+	// 全局白名单按 SteamId 匹配，各服务器中的 PlayerUid 不同
+	player.PlayerUID = ""
+	setAuditTarget(c, player.SteamID)
+	if err := service.AddGlobalWhitelist(database.GetDB(), player); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// removeGlobalWhitelist godoc
+//
+//	@Summary		Remove Global White List
+//	@Description	Remove a player from the white list of all servers
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			steam_id	path		string	true	"Steam ID"
+//
+//	@Success		200			{object}	SuccessResponse
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse
+//	@Failure		404			{object}	ErrorResponse
+//	@Router			/api/global/whitelist/{steam_id} [delete]
+func removeGlobalWhitelist(c *gin.Context) {
+	err := service.RemoveGlobalWhitelist(database.GetDB(), normalizeSteamId(c.Param("steam_id")))
+	if err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{"error": "未在白名单中找到玩家"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// listGlobalBans godoc
+//
+//	@Summary		List Global Bans
+//	@Description	List bans enforced on all servers, newest first
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	[]database.Ban
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/global/ban [get]
+func listGlobalBans(c *gin.Context) {
+	bans, err := service.ListGlobalBans(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, bans)
+}
+
+// addGlobalBan godoc
+//
+//	@Summary		Add Global Ban
+//	@Description	Ban a SteamID on all servers, servers that fail are banned again when the player joins them
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			ban	body		GlobalBanInfo	true	"Ban"
+//
+//	@Success		200	{object}	GlobalBanResponse
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/global/ban [post]
+func addGlobalBan(c *gin.Context) {
+	var banInfo GlobalBanInfo
+	if err := c.ShouldBindJSON(&banInfo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if banInfo.Duration < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的封禁时长"})
+		return
+	}
+
+	ban := database.Ban{
+		SteamId:   normalizeSteamId(banInfo.SteamId),
+		Nickname:  banInfo.Nickname,
+		Reason:    banInfo.Reason,
+		Operator:  auth.GetUsername(c),
+		CreatedAt: time.Now(),
+	}
+	if banInfo.Duration > 0 {
+		ban.ExpiresAt = ban.CreatedAt.Add(time.Duration(banInfo.Duration) * time.Second)
+	}
+	setAuditTarget(c, ban.SteamId)
+	if ban.Nickname == "" {
+		if identities, err := listAllIdentities(); err == nil {
+			for _, identity := range identities {
+				if identity.SteamId == ban.SteamId {
+					ban.Nickname = identity.Nickname
+					break
+				}
+			}
+		}
+	}
+	if err := service.PutGlobalBan(database.GetDB(), ban); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	failed := task.ApplyGlobalBan(ban)
+	webhook.Emit(webhook.EventPlayerBan, ban.Nickname+" was banned on all servers by "+ban.Operator, map[string]interface{}{
+		"nickname":   ban.Nickname,
+		"steam_id":   ban.SteamId,
+		"reason":     ban.Reason,
+		"operator":   ban.Operator,
+		"expires_at": ban.ExpiresAt,
+		"global":     true,
+	})
+	c.JSON(http.StatusOK, GlobalBanResponse{Success: true, FailedServers: failed})
+}
+
+// removeGlobalBan godoc
+//
+//	@Summary		Remove Global Ban
+//	@Description	Unban a SteamID on all servers, servers that also ban the player themselves keep their ban.
+//	@Description	Servers that fail are listed in pending_unban and retried every minute
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			steam_id	path		string	true	"Steam ID"
+//
+//	@Success		200			{object}	GlobalBanResponse
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse
+//	@Failure		404			{object}	ErrorResponse
+//	@Router			/api/global/ban/{steam_id} [delete]
+func removeGlobalBan(c *gin.Context) {
+	db := database.GetDB()
+	ban, err := service.GetGlobalBan(db, normalizeSteamId(c.Param("steam_id")))
+	if err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{"error": "未找到封禁记录"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	failed, err := task.RemoveGlobalBan(ban)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	webhook.Emit(webhook.EventPlayerUnban, ban.Nickname+" was unbanned on all servers by "+auth.GetUsername(c), map[string]interface{}{
+		"nickname": ban.Nickname,
+		"steam_id": ban.SteamId,
+		"operator": auth.GetUsername(c),
+		"global":   true,
+	})
+	c.JSON(http.StatusOK, GlobalBanResponse{Success: true, FailedServers: failed})
+}
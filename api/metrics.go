@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+// listServerMetricsHistory godoc
+//
+//	@Summary		List Server Metrics History
+//	@Description	List collected server metrics, raw samples are kept for 24 hours and downsampled to hourly points afterwards
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Param			startTime	query		int		false	"Start time in milliseconds"
+//	@Param			endTime		query		int		false	"End time in milliseconds"
+//	@Param			resolution	query		string	false	"raw or hour, defaults to hour when startTime is more than 24 hours ago"	Enums(raw, hour)
+//
+//	@Success		200			{array}		database.MetricsPoint
+//	@Failure		400			{object}	ErrorResponse
+//	@Router			/api/server/metrics/history [get]
+func listServerMetricsHistory(c *gin.Context) {
+	var startTime, endTime time.Time
+	if startTimeStr := c.Query("startTime"); startTimeStr != "" {
+		startTimestamp, err := strconv.ParseInt(startTimeStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的开始时间"})
+			return
+		}
+		startTime = time.UnixMilli(startTimestamp)
+	}
+	if endTimeStr := c.Query("endTime"); endTimeStr != "" {
+		endTimestamp, err := strconv.ParseInt(endTimeStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的结束时间"})
+			return
+		}
+		endTime = time.UnixMilli(endTimestamp)
+	}
+
+	// 查询范围超出原始数据的保留时间时默认按小时汇总
+	resolution := c.Query("resolution")
+	switch resolution {
+	case "":
+		resolution = service.MetricsResolutionRaw
+		if startTime.IsZero() || startTime.Before(time.Now().Add(-24*time.Hour)) {
+			resolution = service.MetricsResolutionHour
+		}
+	case service.MetricsResolutionRaw, service.MetricsResolutionHour:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 resolution"})
+		return
+	}
+
+	points, err := service.ListMetrics(serverDB(c), resolution, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, points)
+}
@@ -1,16 +1,27 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
 	"github.com/qycnet/palworld-server-tool-main/internal/database"
-	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/internal/task"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
 	"github.com/qycnet/palworld-server-tool-main/service"
 )
 
+type BanInfo struct {
+	Reason string `json:"reason"`
+	// 封禁时长，单位秒，为 0 时永久封禁
+	Duration int64 `json:"duration"`
+}
+
 type PlayerOrderBy string
 
 const (
@@ -28,17 +39,22 @@ const (
 //
 //	@Success		200	{object}	[]database.OnlinePlayer
 //	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
 //	@Router			/api/online_player [get]
 func listOnlinePlayers(c *gin.Context) {
 	// 获取在线玩家列表
-	onlinePLayers, err := tool.ShowPlayers()
+	onlinePLayers, err := currentServer(c).ShowPlayers()
 	if err != nil {
 		// 如果出现错误，返回错误状态码和错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	// 将在线玩家列表存入数据库
-	service.PutPlayersOnline(database.GetDB(), onlinePLayers)
+	service.PutPlayersOnline(serverDB(c), onlinePLayers)
+	if shouldRedact(c) {
+		onlinePLayers = redactOnlinePlayers(onlinePLayers)
+	}
 	// 返回成功状态码和在线玩家列表
 	c.JSON(http.StatusOK, onlinePLayers)
 }
@@ -71,7 +87,7 @@ func putPlayers(c *gin.Context) {
 	}
 
 	// 调用service.PutPlayers函数，将players插入数据库
-	if err := service.PutPlayers(database.GetDB(), players); err != nil {
+	if err := service.PutPlayers(serverDB(c), players); err != nil {
 		// 如果插入失败，返回400错误码和错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -94,6 +110,8 @@ func putPlayers(c *gin.Context) {
 //
 //	@Success		200			{object}	[]database.TersePlayer
 //	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse
 //	@Router			/api/player [get]
 func listPlayers(c *gin.Context) {
 	// 获取查询参数 order_by
@@ -102,7 +120,7 @@ func listPlayers(c *gin.Context) {
 	desc := c.Query("desc")
 
 	// 从数据库中获取玩家列表
-	players, err := service.ListPlayers(database.GetDB())
+	players, err := service.ListPlayers(serverDB(c))
 	if err != nil {
 		// 如果获取玩家列表失败，则返回错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -135,6 +153,12 @@ func listPlayers(c *gin.Context) {
 		})
 	}
 
+	if shouldRedact(c) {
+		fields := redactFields()
+		for i := range players {
+			redactOnlinePlayer(&players[i].OnlinePlayer, fields)
+		}
+	}
 	// 返回排序后的玩家列表
 	c.JSON(http.StatusOK, players)
 }
@@ -151,11 +175,13 @@ func listPlayers(c *gin.Context) {
 //
 //	@Success		200			{object}	database.Player
 //	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse
 //	@Failure		404			{object}	EmptyResponse
 //	@Router			/api/player/{player_uid} [get]
 func getPlayer(c *gin.Context) {
 	// 从数据库中获取玩家信息
-	player, err := service.GetPlayer(database.GetDB(), c.Param("player_uid"))
+	player, err := service.GetPlayer(serverDB(c), c.Param("player_uid"))
 	if err != nil {
 		// 检查错误类型
 		if err == service.ErrNoRecord {
@@ -167,10 +193,109 @@ func getPlayer(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if shouldRedact(c) {
+		redactPlayer(&player, redactFields())
+	}
 	// 返回玩家信息，状态码为200
 	c.JSON(http.StatusOK, player)
 }
 
+// listPlayerHistory godoc
+//
+//	@Summary		List Player History
+//	@Description	List player snapshots recorded on every sav sync within a time range
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			player_uid	path		string	true	"Player UID"
+//	@Param			startTime	query		int		false	"Start time in timestamp"
+//	@Param			endTime		query		int		false	"End time in timestamp"
+//
+//	@Success		200			{array}		database.PlayerSnapshot
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		404			{object}	EmptyResponse
+//	@Router			/api/player/{player_uid}/history [get]
+func listPlayerHistory(c *gin.Context) {
+	// 解析时间范围
+	startTime, endTime, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从数据库中获取玩家历史记录
+	snapshots, err := service.ListPlayerHistory(serverDB(c), c.Param("player_uid"), startTime, endTime)
+	if err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// listPlayerSessions godoc
+//
+//	@Summary		List Player Sessions
+//	@Description	List player online sessions within a time range
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			player_uid	path		string	true	"Player UID"
+//	@Param			startTime	query		int		false	"Start time in timestamp"
+//	@Param			endTime		query		int		false	"End time in timestamp"
+//
+//	@Success		200			{array}		database.PlayerSession
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		404			{object}	EmptyResponse
+//	@Router			/api/player/{player_uid}/sessions [get]
+func listPlayerSessions(c *gin.Context) {
+	// 解析时间范围
+	startTime, endTime, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 从数据库中获取玩家会话记录
+	sessions, err := service.ListPlayerSessions(serverDB(c), c.Param("player_uid"), startTime, endTime)
+	if err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// parseTimeRange 解析毫秒时间戳形式的 startTime 和 endTime 查询参数，未传入时为零值
+func parseTimeRange(c *gin.Context) (time.Time, time.Time, error) {
+	var startTime, endTime time.Time
+	if s := c.Query("startTime"); s != "" {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return startTime, endTime, errors.New("无效的开始时间")
+		}
+		startTime = time.UnixMilli(ms)
+	}
+	if s := c.Query("endTime"); s != "" {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return startTime, endTime, errors.New("无效的结束时间")
+		}
+		endTime = time.UnixMilli(ms)
+	}
+	return startTime, endTime, nil
+}
+
 // kickPlayer godoc
 //
 //	@Summary		Kick Player
@@ -190,7 +315,7 @@ func kickPlayer(c *gin.Context) {
 	// 获取URL参数中的玩家UID
 	playerUid := c.Param("player_uid")
 	// 根据玩家UID获取玩家信息
-	player, err := service.GetPlayer(database.GetDB(), playerUid)
+	player, err := service.GetPlayer(serverDB(c), playerUid)
 	if err != nil {
 		// 如果错误为没有记录的错误
 		if err == service.ErrNoRecord {
@@ -203,12 +328,18 @@ func kickPlayer(c *gin.Context) {
 		return
 	}
 	// 调用工具函数踢出玩家
-	err = tool.KickPlayer(fmt.Sprintf("steam_%s", player.SteamId))
+	err = currentServer(c).KickPlayer(fmt.Sprintf("steam_%s", player.SteamId))
 	if err != nil {
 		// 如果踢出玩家失败，返回400状态码，提示错误详情
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	task.Emit(currentServer(c), webhook.EventPlayerKick, player.Nickname+" was kicked by "+auth.GetUsername(c), map[string]interface{}{
+		"player_uid": player.PlayerUid,
+		"nickname":   player.Nickname,
+		"steam_id":   player.SteamId,
+		"operator":   auth.GetUsername(c),
+	})
 	// 踢出玩家成功，返回200状态码，提示操作成功
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -222,6 +353,7 @@ func kickPlayer(c *gin.Context) {
 //	@Produce		json
 //	@Security		ApiKeyAuth
 //	@Param			player_uid	path		string	true	"Player UID"
+//	@Param			ban_info	body		BanInfo	false	"Ban Info"
 //
 //	@Success		200			{object}	SuccessResponse
 //	@Failure		400			{object}	ErrorResponse
@@ -232,8 +364,21 @@ func banPlayer(c *gin.Context) {
 	// 从请求中获取玩家UID
 	playerUid := c.Param("player_uid")
 
+	// 请求体可选，用于指定封禁原因和时长
+	var banInfo BanInfo
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&banInfo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if banInfo.Duration < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的封禁时长"})
+		return
+	}
+
 	// 从数据库中获取玩家信息
-	player, err := service.GetPlayer(database.GetDB(), playerUid)
+	player, err := service.GetPlayer(serverDB(c), playerUid)
 	if err != nil {
 		// 如果错误是未找到记录
 		if err == service.ErrNoRecord {
@@ -247,13 +392,38 @@ func banPlayer(c *gin.Context) {
 	}
 
 	// 封禁玩家
-	err = tool.BanPlayer(fmt.Sprintf("steam_%s", player.SteamId))
+	err = currentServer(c).BanPlayer(fmt.Sprintf("steam_%s", player.SteamId))
 	if err != nil {
 		// 如果封禁失败，返回400错误，并输出错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 记录封禁信息
+	ban := database.Ban{
+		PlayerUid: player.PlayerUid,
+		SteamId:   player.SteamId,
+		Nickname:  player.Nickname,
+		Reason:    banInfo.Reason,
+		Operator:  auth.GetUsername(c),
+		CreatedAt: time.Now(),
+	}
+	if banInfo.Duration > 0 {
+		ban.ExpiresAt = ban.CreatedAt.Add(time.Duration(banInfo.Duration) * time.Second)
+	}
+	if err = service.PutBan(serverDB(c), ban); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task.Emit(currentServer(c), webhook.EventPlayerBan, player.Nickname+" was banned by "+ban.Operator, map[string]interface{}{
+		"player_uid": ban.PlayerUid,
+		"nickname":   ban.Nickname,
+		"steam_id":   ban.SteamId,
+		"reason":     ban.Reason,
+		"operator":   ban.Operator,
+		"expires_at": ban.ExpiresAt,
+	})
+
 	// 返回200成功状态，表示封禁成功
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -261,7 +431,7 @@ func banPlayer(c *gin.Context) {
 // unbanPlayer godoc
 //
 //	@Summary		Unban Player
-//	@Description	Unban Player
+//	@Description	Unban Player, the in-game ban is kept while a global ban for the player is active
 //	@Tags			Player
 //	@Accept			json
 //	@Produce		json
@@ -277,31 +447,80 @@ func unbanPlayer(c *gin.Context) {
 	// 获取请求中的玩家UID
 	playerUid := c.Param("player_uid")
 
-	// 从数据库中获取玩家信息
-	player, err := service.GetPlayer(database.GetDB(), playerUid)
-	if err != nil {
-		// 如果没有找到玩家记录
-		if err == service.ErrNoRecord {
-			c.JSON(http.StatusNotFound, gin.H{"error": "未找到玩家"})
+	// 优先使用封禁记录中的 SteamId，玩家记录可能已被删除
+	var steamId, nickname string
+	if ban, err := service.GetBan(serverDB(c), playerUid); err == nil {
+		steamId, nickname = ban.SteamId, ban.Nickname
+	} else {
+		// 从数据库中获取玩家信息
+		player, err := service.GetPlayer(serverDB(c), playerUid)
+		if err != nil {
+			// 如果没有找到玩家记录
+			if err == service.ErrNoRecord {
+				c.JSON(http.StatusNotFound, gin.H{"error": "未找到玩家"})
+				return
+			}
+			// 如果出现其他错误
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		// 如果出现其他错误
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		steamId, nickname = player.SteamId, player.Nickname
 	}
 
-	// 解除对玩家的封禁
-	err = tool.UnBanPlayer(fmt.Sprintf("steam_%s", player.SteamId))
-	if err != nil {
-		// 如果出现错误
+	// 仍有生效的全局封禁时只删除服务器自身的封禁记录，保留游戏内的封禁
+	global := task.GlobalBanActive(steamId)
+	if !global {
+		// 解除对玩家的封禁
+		err := currentServer(c).UnBanPlayer(fmt.Sprintf("steam_%s", steamId))
+		if err != nil {
+			// 如果出现错误
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 删除封禁记录
+	if err := service.DeleteBan(serverDB(c), playerUid); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if global {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+	task.Emit(currentServer(c), webhook.EventPlayerUnban, nickname+" was unbanned by "+auth.GetUsername(c), map[string]interface{}{
+		"player_uid": playerUid,
+		"nickname":   nickname,
+		"steam_id":   steamId,
+		"operator":   auth.GetUsername(c),
+	})
 
 	// 封禁解除成功，返回成功信息
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// listBans godoc
+//
+//	@Summary		List Bans
+//	@Description	List players banned by this tool
+//	@Tags			Player
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{array}		database.Ban
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Router			/api/ban [get]
+func listBans(c *gin.Context) {
+	bans, err := service.ListBans(serverDB(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, bans)
+}
+
 // addWhite godoc
 //
 //	@Summary		Add White List
@@ -322,7 +541,8 @@ func addWhite(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := service.AddWhitelist(database.GetDB(), player); err != nil {
+	setAuditTarget(c, whitelistTarget(player))
+	if err := service.AddWhitelist(serverDB(c), player); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -341,7 +561,7 @@ func addWhite(c *gin.Context) {
 //	@Router			/api/whitelist [get]
 func listWhite(c *gin.Context) {
 	// 从数据库中获取白名单玩家列表
-	players, err := service.ListWhitelist(database.GetDB())
+	players, err := service.ListWhitelist(serverDB(c))
 	if err != nil {
 		// 如果获取白名单玩家列表时出错，返回错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -375,8 +595,9 @@ func removeWhite(c *gin.Context) {
 		return
 	}
 
+	setAuditTarget(c, whitelistTarget(player))
 	// 调用 service.RemoveWhitelist 方法从白名单中移除 player
-	if err := service.RemoveWhitelist(database.GetDB(), player); err != nil {
+	if err := service.RemoveWhitelist(serverDB(c), player); err != nil {
 		// 如果移除失败，返回状态码 400 和错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -386,6 +607,17 @@ func removeWhite(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// whitelistTarget 返回白名单玩家在审计日志中的操作对象，依次使用 PlayerUID、SteamID 和名称
+func whitelistTarget(player database.PlayerW) string {
+	if player.PlayerUID != "" {
+		return player.PlayerUID
+	}
+	if player.SteamID != "" {
+		return player.SteamID
+	}
+	return player.Name
+}
+
 // putWhite godoc
 //
 //	@Summary		Put White List
@@ -412,7 +644,7 @@ func putWhite(c *gin.Context) {
 	}
 
 	// 调用service.PutWhitelist方法，将players数据存入白名单
-	if err := service.PutWhitelist(database.GetDB(), players); err != nil {
+	if err := service.PutWhitelist(serverDB(c), players); err != nil {
 		// 如果存入白名单失败，返回错误状态码和错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"golang.org/x/time/rate"
+)
+
+// 超过该时间没有请求的客户端会被清理
+const limiterIdle = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimit 按客户端 IP 限制请求频率，由 web.rate_limit 和 web.rate_burst 配置。
+// 只有来自 web.trusted_proxies 的请求才会使用 X-Forwarded-For 中的 IP
+func rateLimit() gin.HandlerFunc {
+	var (
+		mu        sync.Mutex
+		clients   = make(map[string]*clientLimiter)
+		lastSweep = time.Now()
+	)
+	return func(c *gin.Context) {
+		limit := viper.GetFloat64("web.rate_limit")
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		burst := viper.GetInt("web.rate_burst")
+		if burst < 1 {
+			burst = 1
+		}
+
+		now := time.Now()
+		mu.Lock()
+		if now.Sub(lastSweep) > time.Minute {
+			for ip, client := range clients {
+				if now.Sub(client.lastSeen) > limiterIdle {
+					delete(clients, ip)
+				}
+			}
+			lastSweep = now
+		}
+		client, ok := clients[c.ClientIP()]
+		if !ok || client.limiter.Limit() != rate.Limit(limit) || client.limiter.Burst() != burst {
+			client = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(limit), burst)}
+			clients[c.ClientIP()] = client
+		}
+		client.lastSeen = now
+		allowed := client.limiter.Allow()
+		mu.Unlock()
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(1/limit)+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/qycnet/palworld-server-tool-main/internal/database"
-	"github.com/qycnet/palworld-server-tool-main/internal/tool"
 	"github.com/qycnet/palworld-server-tool-main/service"
 )
 
@@ -56,7 +55,7 @@ func sendRconCommand(c *gin.Context) {
 	// 构造执行命令
 	execCommand := fmt.Sprintf("%s %s", rcon.Command, req.Content)
 	// 执行命令
-	response, err := tool.CustomCommand(execCommand)
+	response, err := currentServer(c).CustomCommand(execCommand)
 	if err != nil {
 		// 如果执行命令失败，返回错误响应
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -168,6 +167,7 @@ func addRconCommand(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	setAuditTarget(c, rcon.Command)
 	// 调用 service.AddRconCommand 方法将 rcon 添加到数据库中
 	err := service.AddRconCommand(database.GetDB(), rcon)
 	// 如果添加失败，返回 HTTP 400 错误和错误信息
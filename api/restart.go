@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/task"
+)
+
+type RestartRequest struct {
+	// 距离重启的秒数
+	Seconds int `json:"seconds"`
+}
+
+// getRestart godoc
+//
+//	@Summary		Get Restart
+//	@Description	Get the next planned restart and its countdown settings
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	task.RestartStatus
+//	@Failure		401	{object}	ErrorResponse
+//	@Router			/api/server/restart [get]
+func getRestart(c *gin.Context) {
+	c.JSON(http.StatusOK, task.GetRestartStatus(currentServer(c)))
+}
+
+// planRestart godoc
+//
+//	@Summary		Plan Restart
+//	@Description	Restart the server after a countdown, replacing the planned restart
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			restart	body		RestartRequest	true	"Restart"
+//
+//	@Success		200		{object}	task.RestartStatus
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Router			/api/server/restart [post]
+func planRestart(c *gin.Context) {
+	var req RestartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Seconds <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seconds 必须大于 0"})
+		return
+	}
+	c.JSON(http.StatusOK, task.PlanRestart(currentServer(c), req.Seconds))
+}
+
+// postponeRestart godoc
+//
+//	@Summary		Postpone Restart
+//	@Description	Postpone the planned restart by the given seconds
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			restart	body		RestartRequest	true	"Postpone"
+//
+//	@Success		200		{object}	task.RestartStatus
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Failure		404		{object}	ErrorResponse
+//	@Router			/api/server/restart/postpone [post]
+func postponeRestart(c *gin.Context) {
+	var req RestartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Seconds <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seconds 必须大于 0"})
+		return
+	}
+	status, err := task.PostponeRestart(currentServer(c), req.Seconds)
+	if err != nil {
+		restartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// cancelRestart godoc
+//
+//	@Summary		Cancel Restart
+//	@Description	Cancel the planned restart, a cron restart only skips this occurrence
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	task.RestartStatus
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Failure		404	{object}	ErrorResponse
+//	@Router			/api/server/restart/cancel [post]
+func cancelRestart(c *gin.Context) {
+	status, err := task.CancelRestart(currentServer(c))
+	if err != nil {
+		restartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func restartError(c *gin.Context, err error) {
+	if errors.Is(err, task.ErrNoRestart) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -72,86 +72,219 @@ func RegisterRouter(r *gin.Engine) {
 	r.POST("/api/login", loginHandler)
 	// 注册Swagger路由
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// 注册Prometheus指标路由
+	r.GET("/metrics", metricsAuth(), getMetrics)
 
 	// 创建一个新的API组
 	apiGroup := r.Group("/api")
+	// 匿名接口的请求频率限制，默认服务器和其他服务器的接口共用
+	limiter := rateLimit()
 
 	// 创建匿名访问的路由组
 	anonymousGroup := apiGroup.Group("")
+	// 限制匿名接口的请求频率
+	anonymousGroup.Use(limiter)
 	{
-		// 获取服务器信息
-		anonymousGroup.GET("/server", getServer)
 		// 获取服务器工具信息
 		anonymousGroup.GET("/server/tool", getServerTool)
-		// 获取服务器性能指标
-		anonymousGroup.GET("/server/metrics", getServerMetrics)
-		// 获取玩家列表
-		anonymousGroup.GET("/player", listPlayers)
-		// 获取指定玩家的信息
-		anonymousGroup.GET("/player/:player_uid", getPlayer)
-		// 获取在线玩家列表
-		anonymousGroup.GET("/online_player", listOnlinePlayers)
-		// 获取公会列表
-		anonymousGroup.GET("/guild", listGuilds)
-		// 获取指定管理员玩家的公会信息
-		anonymousGroup.GET("/guild/:admin_player_uid", getGuild)
+		// 订阅实时事件流
+		anonymousGroup.GET("/events", streamEvents)
+		// 获取所有服务器
+		anonymousGroup.GET("/servers", listServers)
 	}
 
-	// 创建需要认证的路由组
+	// 创建需要认证的路由组，登录用户均可访问
 	authGroup := apiGroup.Group("")
 	authGroup.Use(auth.JWTAuthMiddleware())
 	{
-		// 获取玩家列表
-		//authGroup.GET("/player", listPlayers)
+		// 获取按 SteamID 关联的所有服务器的玩家
+		authGroup.GET("/identity", listIdentities)
+		// 获取指定 SteamID 在所有服务器中的玩家
+		authGroup.GET("/identity/:steam_id", getIdentity)
+		// 获取全局白名单
+		authGroup.GET("/global/whitelist", listGlobalWhitelist)
+	}
+
+	// 创建需要协管员权限的路由组
+	moderatorGroup := authGroup.Group("")
+	moderatorGroup.Use(auth.RequireRole(auth.RoleModerator))
+	{
+		// 添加全局白名单
+		moderatorGroup.POST("/global/whitelist", audit("global_whitelist_add", ""), addGlobalWhitelist)
+		// 删除全局白名单
+		moderatorGroup.DELETE("/global/whitelist/:steam_id", audit("global_whitelist_remove", "steam_id"), removeGlobalWhitelist)
+		// 获取全局封禁列表
+		moderatorGroup.GET("/global/ban", listGlobalBans)
+		// 在所有服务器上封禁玩家
+		moderatorGroup.POST("/global/ban", audit("global_ban", ""), addGlobalBan)
+		// 在所有服务器上解除封禁
+		moderatorGroup.DELETE("/global/ban/:steam_id", audit("global_unban", "steam_id"), removeGlobalBan)
+	}
+
+	// 创建需要管理员权限的路由组
+	adminGroup := authGroup.Group("")
+	adminGroup.Use(auth.RequireRole(auth.RoleAdmin))
+	{
+		// 获取RCON命令列表
+		adminGroup.GET("/rcon", listRconCommand)
+		// 添加RCON命令
+		adminGroup.POST("/rcon", audit("rcon_add", ""), addRconCommand)
+		// 导入RCON命令
+		adminGroup.POST("/rcon/import", audit("rcon_import", ""), importRconCommands)
+		// 更新指定UUID的RCON命令
+		adminGroup.PUT("/rcon/:uuid", audit("rcon_put", "uuid"), putRconCommand)
+		// 删除指定UUID的RCON命令
+		adminGroup.DELETE("/rcon/:uuid", audit("rcon_delete", "uuid"), removeRconCommand)
+		// 获取 webhook 投递记录
+		adminGroup.GET("/webhook/delivery", listWebhookDeliveries)
+		// 发送测试事件到 webhook
+		adminGroup.POST("/webhook/test", audit("webhook_test", ""), testWebhook)
+		// 获取用户列表
+		adminGroup.GET("/user", listUsers)
+		// 添加用户
+		adminGroup.POST("/user", audit("user_add", ""), addUser)
+		// 更新指定用户
+		adminGroup.PUT("/user/:username", audit("user_put", "username"), putUser)
+		// 删除指定用户
+		adminGroup.DELETE("/user/:username", audit("user_delete", "username"), deleteUser)
+		// 获取审计日志
+		adminGroup.GET("/audit", listAuditLogs)
+		// 获取配置，密钥已隐藏
+		adminGroup.GET("/config", getConfig)
+		// 修改配置并写入配置文件
+		adminGroup.PUT("/config", audit("config_put", ""), putConfig)
+	}
+
+	// 默认服务器的接口
+	registerServerRoutes(anonymousGroup, authGroup, moderatorGroup, adminGroup)
+
+	// servers 中配置的服务器的接口，路径为 /api/servers/:server_id/...
+	serverGroup := apiGroup.Group("/servers/:server_id")
+	serverGroup.Use(serverScope())
+	serverAnonymousGroup := serverGroup.Group("")
+	serverAnonymousGroup.Use(limiter)
+	serverAuthGroup := serverGroup.Group("")
+	serverAuthGroup.Use(auth.JWTAuthMiddleware())
+	serverModeratorGroup := serverAuthGroup.Group("")
+	serverModeratorGroup.Use(auth.RequireRole(auth.RoleModerator))
+	serverAdminGroup := serverAuthGroup.Group("")
+	serverAdminGroup.Use(auth.RequireRole(auth.RoleAdmin))
+	registerServerRoutes(serverAnonymousGroup, serverAuthGroup, serverModeratorGroup, serverAdminGroup)
+}
+
+// registerServerRoutes 注册操作单个服务器的接口，处理器通过 currentServer 获取要操作的服务器
+func registerServerRoutes(anonymousGroup, authGroup, moderatorGroup, adminGroup *gin.RouterGroup) {
+	{
+		// 获取服务器信息
+		anonymousGroup.GET("/server", getServer)
+		// 获取服务器性能指标
+		anonymousGroup.GET("/server/metrics", getServerMetrics)
+		// 获取服务器性能指标历史
+		anonymousGroup.GET("/server/metrics/history", listServerMetricsHistory)
+		// 获取玩家列表，按 visibility.players 配置控制可见性
+		anonymousGroup.GET("/player", visibility("players"), listPlayers)
 		// 获取指定玩家的信息
-		//authGroup.GET("/player/:player_uid", getPlayer)
-		// 获取在线玩家列表
-		//authGroup.GET("/online_player", listOnlinePlayers)
-		// 获取公会列表
-		//authGroup.GET("/guild", listGuilds)
+		anonymousGroup.GET("/player/:player_uid", visibility("players"), getPlayer)
+		// 获取在线玩家列表，按 visibility.online_players 配置控制可见性
+		anonymousGroup.GET("/online_player", visibility("online_players"), listOnlinePlayers)
+		// 获取公会列表，按 visibility.guilds 配置控制可见性
+		anonymousGroup.GET("/guild", visibility("guilds"), listGuilds)
 		// 获取指定管理员玩家的公会信息
-		//authGroup.GET("/guild/:admin_player_uid", getGuild)
+		anonymousGroup.GET("/guild/:admin_player_uid", visibility("guilds"), getGuild)
+		// 获取公会的等级、帕鲁和据点统计
+		anonymousGroup.GET("/guild/:admin_player_uid/stats", visibility("guilds"), getGuildStats)
+		// 获取玩家游戏时长统计，按 visibility.players 配置控制可见性
+		anonymousGroup.GET("/stats/playtime", visibility("players"), listPlaytime)
+	}
+
+	{
+		// 获取指定玩家的历史记录
+		authGroup.GET("/player/:player_uid/history", listPlayerHistory)
+		// 获取指定玩家的在线会话
+		authGroup.GET("/player/:player_uid/sessions", listPlayerSessions)
+		// 获取白名单列表
+		authGroup.GET("/whitelist", listWhite)
+		// 获取所有公会的历史记录
+		authGroup.GET("/guild/history", listGuildHistory)
+		// 获取已解散的公会
+		authGroup.GET("/guild/archive", listArchivedGuilds)
+		// 获取指定公会的历史记录
+		authGroup.GET("/guild/:admin_player_uid/history", getGuildHistory)
+	}
+
+	{
 		// 发布广播消息
-		authGroup.POST("/server/broadcast", publishBroadcast)
-		// 关闭服务器
-		authGroup.POST("/server/shutdown", shutdownServer)
-		// 更新玩家信息
-		authGroup.PUT("/player", putPlayers)
+		moderatorGroup.POST("/server/broadcast", audit("broadcast", ""), publishBroadcast)
 		// 踢出指定玩家
-		authGroup.POST("/player/:player_uid/kick", kickPlayer)
+		moderatorGroup.POST("/player/:player_uid/kick", audit("kick", "player_uid"), kickPlayer)
 		// 封禁指定玩家
-		authGroup.POST("/player/:player_uid/ban", banPlayer)
+		moderatorGroup.POST("/player/:player_uid/ban", audit("ban", "player_uid"), banPlayer)
 		// 解封指定玩家
-		authGroup.POST("/player/:player_uid/unban", unbanPlayer)
-		// 更新公会信息
-		authGroup.PUT("/guild", putGuilds)
-		// 同步数据
-		authGroup.POST("/sync", syncData)
-		// 获取白名单列表
-		authGroup.GET("/whitelist", listWhite)
+		moderatorGroup.POST("/player/:player_uid/unban", audit("unban", "player_uid"), unbanPlayer)
+		// 获取封禁列表
+		moderatorGroup.GET("/ban", listBans)
 		// 添加白名单
-		authGroup.POST("/whitelist", addWhite)
+		moderatorGroup.POST("/whitelist", audit("whitelist_add", ""), addWhite)
 		// 删除白名单
-		authGroup.DELETE("/whitelist", removeWhite)
+		moderatorGroup.DELETE("/whitelist", audit("whitelist_remove", ""), removeWhite)
 		// 更新白名单
-		authGroup.PUT("/whitelist", putWhite)
-		// 获取RCON命令列表
-		authGroup.GET("/rcon", listRconCommand)
-		// 添加RCON命令
-		authGroup.POST("/rcon", addRconCommand)
-		// 导入RCON命令
-		authGroup.POST("/rcon/import", importRconCommands)
+		moderatorGroup.PUT("/whitelist", audit("whitelist_put", ""), putWhite)
+		// 获取定时公告列表
+		moderatorGroup.GET("/announcement", listAnnouncements)
+		// 添加定时公告
+		moderatorGroup.POST("/announcement", audit("announcement_add", ""), addAnnouncement)
+		// 更新定时公告
+		moderatorGroup.PUT("/announcement/:announcement_id", audit("announcement_put", "announcement_id"), putAnnouncement)
+		// 删除定时公告
+		moderatorGroup.DELETE("/announcement/:announcement_id", audit("announcement_delete", "announcement_id"), deleteAnnouncement)
+	}
+
+	{
+		// 关闭服务器
+		adminGroup.POST("/server/shutdown", audit("shutdown", ""), shutdownServer)
+		// 更新玩家信息
+		adminGroup.PUT("/player", audit("player_put", ""), putPlayers)
+		// 更新公会信息
+		adminGroup.PUT("/guild", audit("guild_put", ""), putGuilds)
+		// 同步数据
+		adminGroup.POST("/sync", audit("sync", ""), syncData)
 		// 发送RCON命令
-		authGroup.POST("/rcon/send", sendRconCommand)
-		// 更新指定UUID的RCON命令
-		authGroup.PUT("/rcon/:uuid", putRconCommand)
-		// 删除指定UUID的RCON命令
-		authGroup.DELETE("/rcon/:uuid", removeRconCommand)
+		adminGroup.POST("/rcon/send", audit("rcon_send", ""), sendRconCommand)
 		// 获取备份列表
-		authGroup.GET("/backup", listBackups)
+		adminGroup.GET("/backup", listBackups)
 		// 下载指定备份
-		authGroup.GET("/backup/:backup_id", downloadBackup)
+		adminGroup.GET("/backup/:backup_id", downloadBackup)
 		// 删除指定备份
-		authGroup.DELETE("/backup/:backup_id", deleteBackup)
+		adminGroup.DELETE("/backup/:backup_id", audit("backup_delete", "backup_id"), deleteBackup)
+		// 校验指定备份
+		adminGroup.POST("/backup/:backup_id/verify", audit("backup_verify", "backup_id"), verifyBackup)
+		// 恢复指定备份到游戏服务器
+		adminGroup.POST("/backup/:backup_id/restore", audit("backup_restore", "backup_id"), restoreBackup)
+		// 获取计划中的重启
+		adminGroup.GET("/server/restart", getRestart)
+		// 安排重启
+		adminGroup.POST("/server/restart", audit("restart", ""), planRestart)
+		// 推迟计划中的重启
+		adminGroup.POST("/server/restart/postpone", audit("restart_postpone", ""), postponeRestart)
+		// 取消计划中的重启
+		adminGroup.POST("/server/restart/cancel", audit("restart_cancel", ""), cancelRestart)
+		// 获取服务器进程状态
+		adminGroup.GET("/server/process", getServerProcess)
+		// 启动服务器进程
+		adminGroup.POST("/server/process/start", audit("process_start", ""), startServerProcess)
+		// 关闭服务器进程
+		adminGroup.POST("/server/process/stop", audit("process_stop", ""), stopServerProcess)
+		// 重启服务器进程
+		adminGroup.POST("/server/process/restart", audit("process_restart", ""), restartServerProcess)
+		// 获取服务器容器资源使用情况
+		adminGroup.GET("/server/container/usage", getContainerUsage)
+		// 获取服务器容器日志
+		adminGroup.GET("/server/container/logs", getContainerLogs)
+		// 启动服务器容器
+		adminGroup.POST("/server/container/start", audit("container_start", ""), startContainer)
+		// 停止服务器容器
+		adminGroup.POST("/server/container/stop", audit("container_stop", ""), stopContainer)
+		// 重启服务器容器
+		adminGroup.POST("/server/container/restart", audit("container_restart", ""), restartContainer)
 	}
 }
@@ -86,7 +86,7 @@ func getServerTool(c *gin.Context) {
 //	@Router			/api/server [get]
 func getServer(c *gin.Context) {
 	// 获取系统信息
-	info, err := tool.Info()
+	info, err := currentServer(c).Info()
 	if err != nil {
 		// 如果获取信息时发生错误，返回错误状态码和错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -108,8 +108,8 @@ func getServer(c *gin.Context) {
 //	@Failure		400	{object}	ErrorResponse
 //	@Router			/api/server/metrics [get]
 func getServerMetrics(c *gin.Context) {
-	// 调用 tool.Metrics() 获取服务器指标
-	metrics, err := tool.Metrics()
+	// 调用 currentServer(c).Metrics() 获取服务器指标
+	metrics, err := currentServer(c).Metrics()
 	if err != nil {
 		// 如果发生错误，则返回错误信息
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -162,7 +162,7 @@ func publishBroadcast(c *gin.Context) {
 		return
 	}
 	// 广播消息
-	if err := tool.Broadcast(req.Message); err != nil {
+	if err := currentServer(c).Broadcast(req.Message); err != nil {
 		// 如果广播失败，则返回错误响应
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -207,7 +207,7 @@ func shutdownServer(c *gin.Context) {
 	}
 
 	// 调用 tool.Shutdown 函数进行关机操作，如果失败则返回错误信息
-	if err := tool.Shutdown(req.Seconds, req.Message); err != nil {
+	if err := currentServer(c).Shutdown(req.Seconds, req.Message); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"go.etcd.io/bbolt"
+)
+
+type ManagedServer struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// serverScope 根据 server_id 参数选择要操作的服务器
+func serverScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, err := tool.GetServer(c.Param("server_id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set("server", server)
+		c.Next()
+	}
+}
+
+// currentServer 返回请求要操作的服务器，不在 /api/servers/:server_id 下时为默认服务器
+func currentServer(c *gin.Context) *tool.Server {
+	if server, ok := c.Get("server"); ok {
+		return server.(*tool.Server)
+	}
+	return tool.DefaultServer()
+}
+
+func serverDB(c *gin.Context) *bbolt.DB {
+	return currentServer(c).DB()
+}
+
+// listServers godoc
+//
+//	@Summary		List Servers
+//	@Description	List all managed game servers, the first one is the default server served by the routes without /servers/{server_id}
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//
+//	@Success		200	{object}	[]ManagedServer
+//	@Router			/api/servers [get]
+func listServers(c *gin.Context) {
+	servers := tool.Servers()
+	infos := make([]ManagedServer, 0, len(servers))
+	for _, server := range servers {
+		infos = append(infos, ManagedServer{Id: server.Id, Name: server.Name})
+	}
+	c.JSON(http.StatusOK, infos)
+}
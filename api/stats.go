@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/task"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+// listPlaytime godoc
+//
+//	@Summary		List Playtime
+//	@Description	List total and last 7 days playtime of every player, in seconds, visibility follows visibility.players
+//	@Tags			Stats
+//	@Accept			json
+//	@Produce		json
+//
+//	@Success		200	{array}		database.PlayerPlaytime
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/stats/playtime [get]
+func listPlaytime(c *gin.Context) {
+	// 统计所有玩家的游戏时长
+	playtimes, err := service.ListPlaytime(serverDB(c), time.Now(), task.SessionTimeout())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, playtimes)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/supervisor"
+)
+
+// getServerProcess godoc
+//
+//	@Summary		Get Server Process
+//	@Description	Get the status of the supervised game server process
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	supervisor.Status
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/server/process [get]
+func getServerProcess(c *gin.Context) {
+	c.JSON(http.StatusOK, supervisor.GetStatus(currentServer(c)))
+}
+
+// startServerProcess godoc
+//
+//	@Summary		Start Server Process
+//	@Description	Start the game server process and restart it when it exits
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	supervisor.Status
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/server/process/start [post]
+func startServerProcess(c *gin.Context) {
+	if err := supervisor.Start(currentServer(c)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, supervisor.GetStatus(currentServer(c)))
+}
+
+// stopServerProcess godoc
+//
+//	@Summary		Stop Server Process
+//	@Description	Stop the game server process, killing it if it does not exit within supervisor.stop_timeout
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	supervisor.Status
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/server/process/stop [post]
+func stopServerProcess(c *gin.Context) {
+	if err := supervisor.Stop(currentServer(c)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, supervisor.GetStatus(currentServer(c)))
+}
+
+// restartServerProcess godoc
+//
+//	@Summary		Restart Server Process
+//	@Description	Stop the game server process and start it again
+//	@Tags			Server
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{object}	supervisor.Status
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/server/process/restart [post]
+func restartServerProcess(c *gin.Context) {
+	if err := supervisor.Restart(currentServer(c)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, supervisor.GetStatus(currentServer(c)))
+}
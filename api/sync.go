@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/qycnet/palworld-server-tool-main/internal/database"
 	"github.com/qycnet/palworld-server-tool-main/internal/task"
 )
 
@@ -35,14 +34,16 @@ func syncData(c *gin.Context) {
 	// 如果from参数为"rest"
 	if from == "rest" {
 		// 异步启动玩家数据同步任务
-		go task.PlayerSync(database.GetDB())
+		server := currentServer(c)
+		go task.ServerPlayerSync(server, server.DB())
 		// 返回成功响应
 		c.JSON(http.StatusOK, gin.H{"success": true})
 		return
 	} else if from == "sav" {
 		// 如果from参数为"sav"
 		// 异步启动sav数据同步任务
-		go task.SavSync()
+		server := currentServer(c)
+		go task.ServerSavSync(server, server.DB())
 		// 返回成功响应
 		c.JSON(http.StatusOK, gin.H{"success": true})
 		return
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+type UserInfo struct {
+	Username string    `json:"username"`
+	Password string    `json:"password"`
+	Role     auth.Role `json:"role"`
+}
+
+// listUsers godoc
+//
+//	@Summary		List Users
+//	@Description	List Users
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//
+//	@Success		200	{array}		database.TerseUser
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Router			/api/user [get]
+func listUsers(c *gin.Context) {
+	users, err := service.ListUsers(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// addUser godoc
+//
+//	@Summary		Add User
+//	@Description	Add User
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			user	body		UserInfo	true	"User Info"
+//
+//	@Success		200		{object}	SuccessResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Router			/api/user [post]
+func addUser(c *gin.Context) {
+	var info UserInfo
+	if err := c.ShouldBindJSON(&info); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 校验用户名、密码和角色
+	if info.Username == "" || info.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名和密码不能为空"})
+		return
+	}
+	if !info.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色"})
+		return
+	}
+
+	hash, err := auth.HashPassword(info.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user := database.User{
+		TerseUser: database.TerseUser{
+			Username:  info.Username,
+			Role:      string(info.Role),
+			CreatedAt: time.Now(),
+		},
+		PasswordHash: hash,
+	}
+	if err := service.AddUser(database.GetDB(), user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// putUser godoc
+//
+//	@Summary		Update User
+//	@Description	Update role or password of a user, empty fields are left unchanged
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			username	path		string		true	"Username"
+//	@Param			user		body		UserInfo	true	"User Info"
+//
+//	@Success		200			{object}	SuccessResponse
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse
+//	@Failure		404			{object}	EmptyResponse
+//	@Router			/api/user/{username} [put]
+func putUser(c *gin.Context) {
+	var info UserInfo
+	if err := c.ShouldBindJSON(&info); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := service.GetUser(database.GetDB(), c.Param("username"))
+	if err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 更新角色
+	if info.Role != "" {
+		if !info.Role.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色"})
+			return
+		}
+		user.Role = string(info.Role)
+	}
+	// 更新密码
+	if info.Password != "" {
+		hash, err := auth.HashPassword(info.Password)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.PasswordHash = hash
+	}
+
+	if err := service.PutUser(database.GetDB(), user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// deleteUser godoc
+//
+//	@Summary		Delete User
+//	@Description	Delete User
+//	@Tags			User
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			username	path		string	true	"Username"
+//
+//	@Success		200			{object}	SuccessResponse
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse
+//	@Failure		404			{object}	EmptyResponse
+//	@Router			/api/user/{username} [delete]
+func deleteUser(c *gin.Context) {
+	if err := service.DeleteUser(database.GetDB(), c.Param("username")); err != nil {
+		if err == service.ErrNoRecord {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
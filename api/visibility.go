@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 所有人可见
+	VisibilityPublic = "public"
+	// 未登录用户看到隐藏敏感字段后的数据
+	VisibilityRedacted = "redacted"
+	// 仅管理员可见
+	VisibilityAdmin = "admin"
+)
+
+// optionalRole 从请求头或 token 参数中读取可选的登录令牌，未携带令牌时返回空角色
+func optionalRole(c *gin.Context) (auth.Role, bool) {
+	tokenString := c.Query("token")
+	if header := c.GetHeader("Authorization"); header != "" {
+		tokenString = strings.TrimPrefix(strings.TrimPrefix(header, "Bearer "), "JWT ")
+	}
+	if tokenString == "" {
+		return "", true
+	}
+	_, role, err := auth.ParseToken(tokenString)
+	if err != nil {
+		return "", false
+	}
+	return role, true
+}
+
+// resourceAccess 判断角色能否访问资源，以及返回的数据是否需要隐藏敏感字段
+func resourceAccess(resource string, role auth.Role) (allowed, redact bool) {
+	switch viper.GetString("visibility." + resource) {
+	case VisibilityAdmin:
+		return role.Allows(auth.RoleAdmin), false
+	case VisibilityRedacted:
+		return true, role == ""
+	default:
+		return true, false
+	}
+}
+
+// visibility 按 visibility 配置限制匿名接口的访问
+func visibility(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := optionalRole(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未授权 - 令牌无效"})
+			return
+		}
+		allowed, redact := resourceAccess(resource, role)
+		if !allowed {
+			if role == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未授权 - 令牌缺失"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+			return
+		}
+		c.Set("redact", redact)
+		c.Next()
+	}
+}
+
+func shouldRedact(c *gin.Context) bool {
+	return c.GetBool("redact")
+}
+
+func redactFields() map[string]bool {
+	fields := make(map[string]bool)
+	for _, field := range viper.GetStringSlice("visibility.redact_fields") {
+		fields[field] = true
+	}
+	return fields
+}
+
+func redactOnlinePlayer(player *database.OnlinePlayer, fields map[string]bool) {
+	if fields["ip"] {
+		player.Ip = ""
+	}
+	if fields["steam_id"] {
+		player.SteamId = ""
+	}
+	if fields["location"] {
+		player.LocationX = 0
+		player.LocationY = 0
+	}
+}
+
+func redactPlayer(player *database.Player, fields map[string]bool) {
+	redactOnlinePlayer(&player.OnlinePlayer, fields)
+	if fields["items"] {
+		player.Items = nil
+	}
+	if fields["pals"] {
+		player.Pals = nil
+	}
+}
+
+func redactGuild(guild *database.Guild, fields map[string]bool) {
+	if !fields["location"] {
+		return
+	}
+	for i := range guild.BaseCamp {
+		guild.BaseCamp[i].LocationX = 0
+		guild.BaseCamp[i].LocationY = 0
+	}
+}
+
+// redactOnlinePlayers 返回隐藏敏感字段后的副本，不修改传入的数据
+func redactOnlinePlayers(players []database.OnlinePlayer) []database.OnlinePlayer {
+	fields := redactFields()
+	redacted := make([]database.OnlinePlayer, len(players))
+	copy(redacted, players)
+	for i := range redacted {
+		redactOnlinePlayer(&redacted[i], fields)
+	}
+	return redacted
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+type WebhookTestRequest struct {
+	// webhook 名称，为空时发送到所有 webhook
+	Name string `json:"name"`
+}
+
+// listWebhookDeliveries godoc
+//
+//	@Summary		List Webhook Deliveries
+//	@Description	List recent webhook deliveries, newest first
+//	@Tags			Webhook
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			webhook	query		string	false	"Webhook name"
+//	@Param			event	query		string	false	"Event type"
+//
+//	@Success		200		{array}		database.WebhookDelivery
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Router			/api/webhook/delivery [get]
+func listWebhookDeliveries(c *gin.Context) {
+	deliveries, err := service.ListWebhookDeliveries(database.GetDB(), c.Query("webhook"), c.Query("event"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// testWebhook godoc
+//
+//	@Summary		Test Webhook
+//	@Description	Send a test event to the configured webhooks and return the delivery results
+//	@Tags			Webhook
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Param			webhook	body		WebhookTestRequest	false	"Webhook"
+//
+//	@Success		200		{array}		database.WebhookDelivery
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Failure		404		{object}	EmptyResponse
+//	@Router			/api/webhook/test [post]
+func testWebhook(c *gin.Context) {
+	var req WebhookTestRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	setAuditTarget(c, req.Name)
+
+	event := webhook.Event{
+		Type:    webhook.EventTest,
+		Time:    time.Now(),
+		Message: "Test message from Palworld Server Tool",
+	}
+	deliveries := make([]database.WebhookDelivery, 0)
+	for _, hook := range webhook.Webhooks() {
+		if req.Name != "" && hook.Name != req.Name {
+			continue
+		}
+		deliveries = append(deliveries, webhook.Send(hook, event))
+	}
+	if req.Name != "" && len(deliveries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -1,17 +1,43 @@
 package auth
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/service"
 	"github.com/spf13/viper"
 )
 
-var SecretKey = []byte(viper.GetString("web.password"))
+// 令牌的签名密钥，配置重新加载后替换，未设置时从配置中读取
+var secret atomic.Value
+
+// SetSecret 更新签名密钥，密钥变化后之前签发的令牌全部失效
+func SetSecret(password string) {
+	if old, ok := secret.Swap([]byte(password)).([]byte); ok && string(old) != password {
+		logger.Warn("web.password 已修改, 之前签发的令牌已失效\n")
+	}
+}
+
+func secretKey() []byte {
+	if key, ok := secret.Load().([]byte); ok {
+		return key
+	}
+	return []byte(viper.GetString("web.password"))
+}
+
+// MatchSecret 检查密码是否与 web.password 一致
+func MatchSecret(password string) bool {
+	return subtle.ConstantTimeCompare(secretKey(), []byte(password)) == 1
+}
 
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -41,49 +67,32 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 解析 token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// 判断 token 的签名方法是否为 HMAC
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				// 如果不是，则返回错误
-				return nil, fmt.Errorf("意外的签名方法: %v", token.Header["alg"])
-			}
-			// 返回密钥
-			return SecretKey, nil
-		})
-
-		// 如果解析 token 时出现错误
+		// 解析 token，角色以数据库中的账号为准
+		username, role, err := ParseToken(tokenString)
 		if err != nil {
-			// 则返回未授权状态，并附带错误信息
+			// 令牌无效或账号已删除时返回未授权状态
 			//c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized - invalid token"})
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未授权 - 令牌无效"})
 			return
 		}
-
-		// 判断 token 是否有效
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// 如果有效，则将 claims 存入上下文
-			c.Set("claims", claims)
-		} else {
-			// 如果无效，则返回未授权状态，并附带错误信息
-			//c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized - invalid claims"})
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未授权 - 无效的声明"})
-			return
-		}
+		c.Set("username", username)
+		c.Set("role", role)
 
 		// 继续执行下一个中间件或处理器
 		c.Next()
 	}
 }
 
-func GenerateToken() (string, error) {
+func GenerateToken(username string, role Role) (string, error) {
 	// 创建一个新的JWT令牌，使用HS256签名方法，并设置过期时间为当前时间加上24小时
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"username": username,
+		"role":     string(role),
+		"exp":      time.Now().Add(time.Hour * 24).Unix(),
 	})
 
-	// 使用SecretKey对令牌进行签名，并转换为字符串
-	tokenString, err := token.SignedString(SecretKey)
+	// 使用密钥对令牌进行签名，并转换为字符串
+	tokenString, err := token.SignedString(secretKey())
 	if err != nil {
 		// 如果签名失败，返回空字符串和错误
 		return "", err
@@ -92,3 +101,35 @@ func GenerateToken() (string, error) {
 	// 返回签名后的令牌字符串和nil错误
 	return tokenString, nil
 }
+
+// ParseToken 校验令牌并返回其中的用户名和角色。使用账号登录的令牌从数据库中读取当前的角色，
+// 账号被删除或降级后令牌立即失效或降级，使用 web.password 登录的令牌用户名为空，角色为管理员
+func ParseToken(tokenString string) (string, Role, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		// 判断 token 的签名方法是否为 HMAC
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("意外的签名方法: %v", token.Header["alg"])
+		}
+		return secretKey(), nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", "", errors.New("无效的声明")
+	}
+	username, _ := claims["username"].(string)
+	role, _ := claims["role"].(string)
+	if username == "" {
+		return "", Role(role), nil
+	}
+	user, err := service.GetUser(database.GetDB(), username)
+	if err != nil {
+		if err == service.ErrNoRecord {
+			return "", "", errors.New("账号不存在")
+		}
+		return "", "", err
+	}
+	return user.Username, Role(user.Role), nil
+}
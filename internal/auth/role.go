@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Role string
+
+const (
+	// 只读访问
+	RoleViewer Role = "viewer"
+	// 踢出、封禁、广播和白名单管理
+	RoleModerator Role = "moderator"
+	// 全部权限，包括关闭服务器、备份、RCON 和账号管理
+	RoleAdmin Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleViewer:    1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Allows 判断当前角色是否拥有 required 角色的权限
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleLevels[r] >= roleLevels[required]
+}
+
+// RequireRole 需在 JWTAuthMiddleware 之后使用
+func RequireRole(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !GetRole(c).Allows(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func GetRole(c *gin.Context) Role {
+	role, _ := c.Get("role")
+	r, _ := role.(Role)
+	return r
+}
+
+func GetUsername(c *gin.Context) string {
+	return c.GetString("username")
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
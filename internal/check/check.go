@@ -0,0 +1,177 @@
+package check
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/storage"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+)
+
+type Status string
+
+const (
+	StatusOk   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Result 一项检查的结果
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Options 控制需要实际连接的检查项
+type Options struct {
+	// 是否读取一次存档，存档较大时耗时较长
+	Save bool
+	// 是否在每个备份存储目标中写入、读取并删除一个临时文件
+	Storage bool
+}
+
+// Config 校验配置文件，每个问题为一项结果
+func Config(conf *config.Config) []Result {
+	err := config.Validate(conf)
+	if err == nil {
+		return []Result{{Name: "配置", Status: StatusOk, Message: "配置有效"}}
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	results := make([]Result, 0, len(errs))
+	for _, e := range errs {
+		results = append(results, Result{Name: "配置", Status: StatusFail, Message: e.Error()})
+	}
+	return results
+}
+
+// Run 校验配置，并检查每个服务器的 REST API、RCON、存档来源以及备份存储目标是否可用
+func Run(conf *config.Config, opts Options) []Result {
+	results := Config(conf)
+	for _, srv := range tool.Servers() {
+		results = append(results, checkServer(srv, opts)...)
+	}
+	if opts.Storage {
+		results = append(results, checkStorage()...)
+	}
+	return results
+}
+
+func checkServer(srv *tool.Server, opts Options) []Result {
+	prefix := srv.Id + " "
+	results := make([]Result, 0, 3)
+
+	if info, err := srv.Info(); err != nil {
+		results = append(results, Result{prefix + "REST API", StatusFail, fmt.Sprintf("无法访问 %s: %v, 请检查 rest.address、rest.password 以及服务器是否开启了 RESTAPIEnabled", srv.Rest.Address, err)})
+	} else {
+		results = append(results, Result{prefix + "REST API", StatusOk, fmt.Sprintf("%s %s", info["name"], info["version"])})
+	}
+
+	if srv.Rcon.Address == "" {
+		results = append(results, Result{prefix + "RCON", StatusWarn, "rcon.address 未设置, 无法执行自定义 RCON 命令"})
+	} else if _, err := srv.CustomCommand("Info"); err != nil {
+		results = append(results, Result{prefix + "RCON", StatusFail, fmt.Sprintf("无法连接 %s: %v, 请检查 rcon.address、rcon.password 以及服务器是否开启了 RCONEnabled", srv.Rcon.Address, err)})
+	} else {
+		results = append(results, Result{prefix + "RCON", StatusOk, srv.Rcon.Address})
+	}
+
+	if opts.Save {
+		if err := srv.CheckSave(); err != nil {
+			results = append(results, Result{prefix + "存档", StatusFail, fmt.Sprintf("无法读取 %q: %v, save.path 应为包含 Level.sav 的目录或对应的 docker://、k8s://、http(s):// 地址", srv.Server.Save.Path, err)})
+		} else {
+			results = append(results, Result{prefix + "存档", StatusOk, srv.Server.Save.Path})
+		}
+	}
+	return results
+}
+
+// checkStorage 在每个备份存储目标中写入、读取并删除一个临时文件，
+// 额外服务器只检查单独设置的 save.backup_targets
+func checkStorage() []Result {
+	results := make([]Result, 0)
+	for _, srv := range tool.Servers() {
+		name := "备份存储"
+		if !srv.IsDefault() {
+			if len(srv.Server.Save.BackupTargets) == 0 {
+				continue
+			}
+			name = srv.Id + " " + name
+		}
+		targets, err := srv.StorageTargets()
+		if err != nil {
+			results = append(results, Result{name, StatusFail, err.Error()})
+			continue
+		}
+		for _, target := range targets {
+			if err := probe(target); err != nil {
+				results = append(results, Result{name + " " + target.Name, StatusFail, err.Error()})
+			} else {
+				results = append(results, Result{name + " " + target.Name, StatusOk, "可以读写"})
+			}
+		}
+	}
+	return results
+}
+
+func probe(target storage.Target) error {
+	name := ".pst-check-" + uuid.New().String()
+	content := []byte("pst")
+	if err := target.Put(name, bytes.NewReader(content), int64(len(content))); err != nil {
+		return fmt.Errorf("无法写入: %v", err)
+	}
+	defer target.Delete(name)
+
+	r, err := target.Get(name)
+	if err != nil {
+		return fmt.Errorf("无法读取: %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("无法读取: %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		return errors.New("读取的内容与写入的不一致")
+	}
+	return target.Delete(name)
+}
+
+// Failed 是否有检查项失败
+func Failed(results []Result) bool {
+	for _, result := range results {
+		if result.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print 输出检查报告
+func Print(w io.Writer, results []Result) {
+	for _, result := range results {
+		fmt.Fprintf(w, "[%s] %s: %s\n", strings.ToUpper(string(result.Status)), result.Name, result.Message)
+	}
+}
+
+// Log 将检查结果写入日志
+func Log(results []Result) {
+	for _, result := range results {
+		switch result.Status {
+		case StatusOk:
+			logger.Infof("%s: %s\n", result.Name, result.Message)
+		case StatusWarn:
+			logger.Warnf("%s: %s\n", result.Name, result.Message)
+		default:
+			logger.Errorf("%s: %s\n", result.Name, result.Message)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"reflect"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -9,41 +10,136 @@ import (
 
 type Config struct {
 	Web struct {
-		Password  string `mapstructure:"password"`
-		Port      int    `mapstructure:"port"`
-		Tls       bool   `mapstructure:"tls"`
-		CertPath  string `mapstructure:"cert_path"`
-		KeyPath   string `mapstructure:"key_path"`
-		PublicUrl string `mapstructure:"public_url"`
+		Password     string `mapstructure:"password"`
+		Port         int    `mapstructure:"port"`
+		Tls          bool   `mapstructure:"tls"`
+		CertPath     string `mapstructure:"cert_path"`
+		KeyPath      string `mapstructure:"key_path"`
+		PublicUrl    string `mapstructure:"public_url"`
+		MetricsToken string `mapstructure:"metrics_token"`
+		// 匿名接口每个客户端每秒允许的请求数，设置为0时不限制
+		RateLimit float64 `mapstructure:"rate_limit"`
+		RateBurst int     `mapstructure:"rate_burst"`
+		// 可信的反向代理 IP 或网段，只有来自这些地址的请求才会使用 X-Forwarded-For 中的客户端 IP
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
 	} `mapstructure:"web"`
 	Task struct {
-		SyncInterval        int    `mapstructure:"sync_interval"`
-		PlayerLogging       bool   `mapstructure:"player_logging"`
-		PlayerLoginMessage  string `mapstructure:"player_login_message"`
-		PlayerLogoutMessage string `mapstructure:"player_logout_message"`
+		SyncInterval         int    `mapstructure:"sync_interval"`
+		PlayerLogging        bool   `mapstructure:"player_logging"`
+		PlayerLoginMessage   string `mapstructure:"player_login_message"`
+		PlayerLogoutMessage  string `mapstructure:"player_logout_message"`
+		RestartCron          string `mapstructure:"restart_cron"`
+		RestartCountdown     []int  `mapstructure:"restart_countdown"`
+		RestartMessage       string `mapstructure:"restart_message"`
+		RestartCancelMessage string `mapstructure:"restart_cancel_message"`
+		MetricsInterval      int    `mapstructure:"metrics_interval"`
+		MetricsKeepDays      int    `mapstructure:"metrics_keep_days"`
+		// 玩家历史快照的保留天数，设置为0时永久保留
+		PlayerHistoryKeepDays int `mapstructure:"player_history_keep_days"`
+		// 审计日志的保留天数，设置为0时永久保留
+		AuditKeepDays   int `mapstructure:"audit_keep_days"`
+		LowFpsThreshold int `mapstructure:"low_fps_threshold"`
 	} `mapstructure:"task"`
-	Rcon struct {
-		Address   string `mapstructure:"address"`
-		Password  string `mapstructure:"password"`
-		UseBase64 bool   `mapstructure:"use_base64"`
-		Timeout   int    `mapstructure:"timeout"`
-	} `mapstructure:"rcon"`
-	Rest struct {
-		Address  string `mapstructure:"address"`
-		Username string `mapstructure:"username"`
-		Password string `mapstructure:"password"`
-		Timeout  int    `mapstructure:"timeout"`
-	} `mapstructure:"rest"`
-	Save struct {
-		Path           string `mapstructure:"path"`
-		DecodePath     string `mapstructure:"decode_path"`
-		SyncInterval   int    `mapstructure:"sync_interval"`
-		BackupInterval int    `mapstructure:"backup_interval"`
-		BackupKeepDays int    `mapstructure:"backup_keep_days"`
-	} `mapstructure:"save"`
-	Manage struct {
+	Rcon Rcon `mapstructure:"rcon"`
+	Rest Rest `mapstructure:"rest"`
+	Save Save `mapstructure:"save"`
+	// 额外管理的游戏服务器，顶层的 rcon、rest、save 为默认服务器
+	Servers    []Server   `mapstructure:"servers"`
+	Supervisor Supervisor `mapstructure:"supervisor"`
+	Manage     struct {
 		KickNonWhitelist bool `mapstructure:"kick_non_whitelist"`
 	}
+	// 匿名接口的可见性，可选 public、redacted、admin
+	Visibility struct {
+		Players       string `mapstructure:"players"`
+		OnlinePlayers string `mapstructure:"online_players"`
+		Guilds        string `mapstructure:"guilds"`
+		// redacted 时对未登录用户隐藏的字段，可选 ip、steam_id、location、items、pals
+		RedactFields []string `mapstructure:"redact_fields"`
+	} `mapstructure:"visibility"`
+	Webhooks []Webhook `mapstructure:"webhooks"`
+}
+
+type Rcon struct {
+	Address   string `mapstructure:"address"`
+	Password  string `mapstructure:"password"`
+	UseBase64 bool   `mapstructure:"use_base64"`
+	Timeout   int    `mapstructure:"timeout"`
+}
+
+type Rest struct {
+	Address  string `mapstructure:"address"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Timeout  int    `mapstructure:"timeout"`
+	// 查询接口结果的缓存时间，单位秒，设置为0时不缓存
+	CacheTtl int `mapstructure:"cache_ttl"`
+}
+
+type Save struct {
+	Path           string `mapstructure:"path"`
+	SyncInterval   int    `mapstructure:"sync_interval"`
+	BackupInterval int    `mapstructure:"backup_interval"`
+	BackupKeepDays int    `mapstructure:"backup_keep_days"`
+	// 备份定时校验间隔，单位秒，设置为0时禁用
+	BackupVerifyInterval int `mapstructure:"backup_verify_interval"`
+	// 备份存储目标，为空时保存到本地 backups 目录
+	BackupTargets []BackupTarget `mapstructure:"backup_targets"`
+}
+
+// Server 一个游戏服务器的连接和存档配置，Id 只能包含字母、数字、- 和 _
+type Server struct {
+	Id   string `mapstructure:"id"`
+	Name string `mapstructure:"name"`
+	Rcon Rcon   `mapstructure:"rcon"`
+	Rest Rest   `mapstructure:"rest"`
+	Save Save   `mapstructure:"save"`
+	// 定时重启的 cron 表达式，为空时不定时重启，默认服务器使用 task.restart_cron
+	RestartCron string `mapstructure:"restart_cron"`
+	// 进程守护，默认服务器使用顶层的 supervisor 配置
+	Supervisor Supervisor `mapstructure:"supervisor"`
+}
+
+// Supervisor 由 pst 启动服务器进程并在退出后自动重启
+type Supervisor struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	Path        string   `mapstructure:"path"`
+	Args        []string `mapstructure:"args"`
+	WorkDir     string   `mapstructure:"work_dir"`
+	StopTimeout int      `mapstructure:"stop_timeout"`
+}
+
+// Webhook 事件通知的目标地址
+type Webhook struct {
+	Name string `mapstructure:"name"`
+	Url  string `mapstructure:"url"`
+	// 消息格式，可选 json、discord、slack，默认为 json
+	Format string `mapstructure:"format"`
+	// 订阅的事件，为空时订阅全部事件
+	Events []string `mapstructure:"events"`
+	// 发送失败时的重试次数
+	Retries int `mapstructure:"retries"`
+}
+
+// BackupTarget 备份存储目标，Type 可选 local、s3、sftp、webdav
+type BackupTarget struct {
+	Name     string `mapstructure:"name"`
+	Type     string `mapstructure:"type"`
+	KeepDays int    `mapstructure:"keep_days"`
+	// 本地目录、SFTP 远程目录，或 S3、WebDAV 中的路径前缀
+	Path string `mapstructure:"path"`
+	// S3 或 WebDAV 的服务地址
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	// SFTP 的地址，格式为 host:port
+	Address    string `mapstructure:"address"`
+	Username   string `mapstructure:"username"`
+	Password   string `mapstructure:"password"`
+	PrivateKey string `mapstructure:"private_key"`
+	HostKey    string `mapstructure:"host_key"`
 }
 
 func Init(cfgFile string, conf *Config) {
@@ -73,24 +169,13 @@ func Init(cfgFile string, conf *Config) {
 	}
 
 	// 设置默认配置
-	viper.SetDefault("web.port", 8080)
-
-	viper.SetDefault("task.sync_interval", 60)
-
-	viper.SetDefault("rcon.timeout", 5)
-	viper.SetDefault("rcon.use_base64", false)
-
-	viper.SetDefault("rest.username", "admin")
-	viper.SetDefault("rest.timeout", 5)
-
-	viper.SetDefault("save.sync_interval", 600)
-	viper.SetDefault("save.backup_interval", 14400)
-	viper.SetDefault("save.backup_keep_days", 7)
+	setDefaults(viper.GetViper())
 
 	// 设置环境变量前缀和替换器
 	viper.SetEnvPrefix("")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
 	viper.AutomaticEnv()
+	bindEnvs(viper.GetViper(), reflect.TypeOf(Config{}), "")
 
 	// 将配置解析到结构体中
 	err = viper.Unmarshal(conf)
@@ -100,3 +185,58 @@ func Init(cfgFile string, conf *Config) {
 		logger.Panicf("无法将配置解码到结构体, %s", err)
 	}
 }
+
+// setDefaults 设置默认配置，校验配置时也使用相同的默认值
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("web.port", 8080)
+	v.SetDefault("web.rate_limit", 5)
+	v.SetDefault("web.rate_burst", 20)
+
+	v.SetDefault("task.sync_interval", 60)
+	v.SetDefault("task.restart_countdown", []int{900, 300, 60, 10})
+	v.SetDefault("task.restart_message", "Server will restart in {remaining}")
+	v.SetDefault("task.restart_cancel_message", "Scheduled restart has been cancelled")
+	v.SetDefault("task.metrics_interval", 60)
+	v.SetDefault("task.metrics_keep_days", 30)
+	v.SetDefault("task.player_history_keep_days", 90)
+	v.SetDefault("task.audit_keep_days", 180)
+	v.SetDefault("task.low_fps_threshold", 10)
+
+	v.SetDefault("rcon.timeout", 5)
+	v.SetDefault("rcon.use_base64", false)
+
+	v.SetDefault("rest.username", "admin")
+	v.SetDefault("rest.timeout", 5)
+	v.SetDefault("rest.cache_ttl", 5)
+
+	v.SetDefault("save.sync_interval", 600)
+	v.SetDefault("save.backup_interval", 14400)
+	v.SetDefault("save.backup_keep_days", 7)
+	v.SetDefault("save.backup_verify_interval", 86400)
+
+	v.SetDefault("supervisor.stop_timeout", 30)
+
+	v.SetDefault("visibility.players", "public")
+	v.SetDefault("visibility.online_players", "public")
+	v.SetDefault("visibility.guilds", "public")
+	v.SetDefault("visibility.redact_fields", []string{"ip", "steam_id", "location", "items", "pals"})
+}
+
+// bindEnvs 注册结构体中的所有配置项，没有配置文件时环境变量也能解析到结构体中
+func bindEnvs(v *viper.Viper, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if field.Type.Kind() == reflect.Struct {
+			bindEnvs(v, field.Type, key)
+			continue
+		}
+		v.BindEnv(key)
+	}
+}
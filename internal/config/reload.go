@@ -0,0 +1,347 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretMask 返回配置时替换密钥的值，保存时值为 SecretMask 的密钥保持不变
+const SecretMask = "******"
+
+var ErrNoConfigFile = errors.New("未使用配置文件, 无法保存配置")
+
+// 值为密钥的配置项，包括 servers、webhooks、backup_targets 中的同名配置
+var secretKeys = map[string]bool{
+	"password":      true,
+	"metrics_token": true,
+	"access_key":    true,
+	"secret_key":    true,
+	"private_key":   true,
+}
+
+// 按完整路径判断的密钥，webhook 地址中包含 Discord、Slack 的令牌
+var secretPaths = regexp.MustCompile(`(^|\.)webhooks\[\d+\]\.url$`)
+
+// IsSecret 判断配置项或请求参数是否为密钥，path 为完整路径，例如 web.password、webhooks[0].url
+func IsSecret(path string) bool {
+	path = strings.ToLower(path)
+	key := path[strings.LastIndexAny(path, ".]")+1:]
+	return secretKeys[key] || secretPaths.MatchString(path)
+}
+
+var (
+	reloadMu    sync.Mutex
+	reloadHooks []func(conf *Config)
+	// 最后一次有效的配置文件内容，重新加载的配置无效时用于恢复
+	lastGood []byte
+)
+
+// OnReload 注册配置重新加载后的回调
+func OnReload(fn func(conf *Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloadHooks = append(reloadHooks, fn)
+}
+
+// Watch 监听配置文件，修改后自动重新加载
+func Watch() {
+	if viper.ConfigFileUsed() == "" {
+		return
+	}
+	if data, err := os.ReadFile(viper.ConfigFileUsed()); err == nil {
+		reloadMu.Lock()
+		lastGood = data
+		reloadMu.Unlock()
+	}
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		if err := Reload(); err != nil {
+			logger.Errorf("配置文件无效, 继续使用之前的配置: %v\n", err)
+		}
+	})
+	viper.WatchConfig()
+}
+
+// Reload 重新读取配置文件，配置无效时恢复为之前的配置
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return reload()
+}
+
+func reload() error {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return ErrNoConfigFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// 保存配置后文件监听会再次触发，内容没有变化时跳过
+	if lastGood != nil && bytes.Equal(data, lastGood) {
+		return nil
+	}
+	conf, err := load(data)
+	if err == nil {
+		err = Validate(conf)
+	}
+	if err != nil {
+		// 文件监听可能已经读取了无效的配置
+		if lastGood != nil {
+			if rerr := viper.ReadConfig(bytes.NewReader(lastGood)); rerr != nil {
+				logger.Errorf("无法恢复之前的配置: %v\n", rerr)
+			}
+		}
+		return err
+	}
+
+	if err = viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	lastGood = data
+	logger.Info("配置已重新加载\n")
+	for _, hook := range reloadHooks {
+		hook(conf)
+	}
+	return nil
+}
+
+// load 使用与 Init 相同的默认值和环境变量解析配置文件内容
+func load(data []byte) (*Config, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	setDefaults(v)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
+	v.AutomaticEnv()
+	bindEnvs(v, reflect.TypeOf(Config{}), "")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	var conf Config
+	if err := v.Unmarshal(&conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// Masked 返回当前生效的配置，密钥替换为 SecretMask
+func Masked() map[string]interface{} {
+	return maskSecrets(viper.AllSettings(), "").(map[string]interface{})
+}
+
+// maskSecrets 复制配置并隐藏密钥，不修改 viper 内部的数据
+func maskSecrets(value interface{}, path string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if s, ok := item.(string); ok && IsSecret(joinPath(path, key)) && s != "" {
+				masked[key] = SecretMask
+				continue
+			}
+			masked[key] = maskSecrets(item, joinPath(path, key))
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = maskSecrets(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+		return masked
+	default:
+		return v
+	}
+}
+
+// Update 将修改合并到配置文件中，校验通过后写入并重新加载，保留文件中的注释。
+// changes 中值为 null 的配置项会被删除，恢复为默认值；列表会被整体替换，
+// 其中值为 SecretMask 的密钥按相同位置从原配置中取回
+func Update(changes map[string]interface{}) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return ErrNoConfigFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return errors.New("配置文件的顶层不是对象")
+	}
+	var current map[string]interface{}
+	if err = doc.Decode(&current); err != nil {
+		return err
+	}
+
+	if err = restoreSecrets(changes, current, ""); err != nil {
+		return err
+	}
+	if err = mergeNode(doc.Content[0], changes); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err = encoder.Close(); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	conf, err := load(out)
+	if err != nil {
+		return err
+	}
+	if err = Validate(conf); err != nil {
+		return err
+	}
+	if err = writeFile(path, out); err != nil {
+		return err
+	}
+	return reload()
+}
+
+// restoreSecrets 将值为 SecretMask 的密钥替换为 current 中相同位置的值
+func restoreSecrets(value interface{}, current interface{}, path string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		cur, _ := current.(map[string]interface{})
+		for key, item := range v {
+			if s, ok := item.(string); ok && s == SecretMask && IsSecret(joinPath(path, key)) {
+				old, ok := lookupKey(cur, key).(string)
+				if !ok {
+					return fmt.Errorf("%s 的值无效", joinPath(path, key))
+				}
+				v[key] = old
+				continue
+			}
+			if err := restoreSecrets(item, lookupKey(cur, key), joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		cur, _ := current.([]interface{})
+		for i, item := range v {
+			var old interface{}
+			if i < len(cur) {
+				old = cur[i]
+			}
+			if err := restoreSecrets(item, old, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func lookupKey(m map[string]interface{}, key string) interface{} {
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// mergeNode 将 changes 合并到 YAML 的对象节点中，对象逐项合并，其他值直接替换
+func mergeNode(node *yaml.Node, changes map[string]interface{}) error {
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		change := changes[key]
+		index := -1
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if strings.EqualFold(node.Content[i].Value, key) {
+				index = i
+				break
+			}
+		}
+
+		if change == nil {
+			if index >= 0 {
+				node.Content = append(node.Content[:index], node.Content[index+2:]...)
+			}
+			continue
+		}
+		if m, ok := change.(map[string]interface{}); ok && index >= 0 && node.Content[index+1].Kind == yaml.MappingNode {
+			if err := mergeNode(node.Content[index+1], m); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var value yaml.Node
+		if err := value.Encode(change); err != nil {
+			return err
+		}
+		if index >= 0 {
+			old := node.Content[index+1]
+			value.LineComment = old.LineComment
+			*old = value
+			continue
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &value)
+	}
+	return nil
+}
+
+// writeFile 先写入临时文件再替换，避免文件监听读到写了一半的配置。
+// 配置文件单独挂载到容器中时无法替换，直接写入
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.yaml")
+	if err == nil {
+		_, err = tmp.Write(data)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), mode)
+		}
+		if err == nil {
+			if err = os.Rename(tmp.Name(), path); err == nil {
+				return nil
+			}
+		}
+		os.Remove(tmp.Name())
+	}
+	return os.WriteFile(path, data, mode)
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ServerIdPattern 服务器 ID 的格式，default 保留给默认服务器
+var ServerIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var visibilityValues = map[string]bool{"public": true, "redacted": true, "admin": true}
+
+var backupTypes = map[string]bool{"": true, "local": true, "s3": true, "sftp": true, "webdav": true}
+
+var webhookFormats = map[string]bool{"": true, "json": true, "discord": true, "slack": true}
+
+// Validate 检查配置是否有效，返回所有问题
+func Validate(conf *Config) error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	// web.password 同时是令牌的签名密钥，为空时任何人都可以伪造令牌
+	check(conf.Web.Password != "", "web.password 不能为空")
+	check(conf.Web.Port > 0 && conf.Web.Port < 65536, "web.port 必须在 1-65535 之间")
+	check(conf.Web.RateLimit >= 0, "web.rate_limit 不能为负数")
+	for i, proxy := range conf.Web.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "web.trusted_proxies[%d] %q 不是有效的 IP 或网段", i, proxy)
+	}
+	check(!conf.Web.Tls || (conf.Web.CertPath != "" && conf.Web.KeyPath != ""), "启用 web.tls 时必须设置 web.cert_path 和 web.key_path")
+
+	intervals := map[string]int{
+		"task.sync_interval":          conf.Task.SyncInterval,
+		"task.metrics_interval":       conf.Task.MetricsInterval,
+		"save.sync_interval":          conf.Save.SyncInterval,
+		"save.backup_interval":        conf.Save.BackupInterval,
+		"save.backup_verify_interval": conf.Save.BackupVerifyInterval,
+	}
+	for key, value := range intervals {
+		check(value >= 0, "%s 不能为负数", key)
+	}
+	if conf.Task.RestartCron != "" {
+		_, err := cron.ParseStandard(conf.Task.RestartCron)
+		check(err == nil, "task.restart_cron 无效: %v", err)
+	}
+
+	for key, value := range map[string]string{
+		"visibility.players":        conf.Visibility.Players,
+		"visibility.online_players": conf.Visibility.OnlinePlayers,
+		"visibility.guilds":         conf.Visibility.Guilds,
+	} {
+		check(visibilityValues[value], "%s 只能为 public、redacted 或 admin", key)
+	}
+
+	errs = append(errs, validateServer("", conf.Rcon, conf.Rest)...)
+	ids := make(map[string]bool, len(conf.Servers))
+	for i, server := range conf.Servers {
+		check(ServerIdPattern.MatchString(server.Id) && server.Id != "default", "servers[%d].id %q 无效", i, server.Id)
+		check(!ids[server.Id], "servers[%d].id %q 重复", i, server.Id)
+		ids[server.Id] = true
+		prefix := fmt.Sprintf("servers[%d].", i)
+		errs = append(errs, validateServer(prefix, server.Rcon, server.Rest)...)
+		for key, value := range map[string]int{
+			"save.sync_interval":          server.Save.SyncInterval,
+			"save.backup_interval":        server.Save.BackupInterval,
+			"save.backup_keep_days":       server.Save.BackupKeepDays,
+			"save.backup_verify_interval": server.Save.BackupVerifyInterval,
+		} {
+			check(value >= 0, "%s%s 不能为负数", prefix, key)
+		}
+		errs = append(errs, validateTargets(prefix, server.Save.BackupTargets)...)
+		check(!server.Supervisor.Enabled || server.Supervisor.Path != "", "%s启用 supervisor 时必须设置 supervisor.path", prefix)
+		if server.RestartCron != "" {
+			_, err := cron.ParseStandard(server.RestartCron)
+			check(err == nil, "%srestart_cron 无效: %v", prefix, err)
+		}
+	}
+
+	errs = append(errs, validateTargets("", conf.Save.BackupTargets)...)
+	check(!conf.Supervisor.Enabled || conf.Supervisor.Path != "", "启用 supervisor 时必须设置 supervisor.path")
+
+	webhookNames := make(map[string]bool, len(conf.Webhooks))
+	for i, webhook := range conf.Webhooks {
+		check(isHttpUrl(webhook.Url), "webhooks[%d].url 必须是 http(s) 地址", i)
+		check(webhookFormats[webhook.Format], "webhooks[%d].format 只能为 json、discord 或 slack", i)
+		// 投递记录按名称区分 webhook，未设置时为 webhook-<序号>
+		name := webhook.Name
+		if name == "" {
+			name = fmt.Sprintf("webhook-%d", i)
+		}
+		check(!webhookNames[name], "webhooks[%d].name %q 重复", i, name)
+		webhookNames[name] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTargets 检查备份存储目标，prefix 为配置项的前缀
+func validateTargets(prefix string, targets []BackupTarget) []error {
+	var errs []error
+	names := make(map[string]bool, len(targets))
+	for i, target := range targets {
+		key := fmt.Sprintf("%ssave.backup_targets[%d]", prefix, i)
+		if !backupTypes[target.Type] {
+			errs = append(errs, fmt.Errorf("%s.type 只能为 local、s3、sftp 或 webdav", key))
+		}
+		name := target.Name
+		if name == "" {
+			name = target.Type
+		}
+		if names[name] {
+			errs = append(errs, fmt.Errorf("%s.name %q 重复", key, name))
+		}
+		names[name] = true
+		if target.Type == "sftp" && target.HostKey == "" {
+			errs = append(errs, fmt.Errorf("%s.host_key 未设置, sftp 需要校验服务器公钥", key))
+		}
+	}
+	return errs
+}
+
+// validateServer 检查服务器的连接地址，prefix 为配置项的前缀
+func validateServer(prefix string, rcon Rcon, rest Rest) []error {
+	var errs []error
+	if !isHttpUrl(rest.Address) {
+		errs = append(errs, fmt.Errorf("%srest.address 必须是 http(s) 地址, 例如 http://127.0.0.1:8212", prefix))
+	}
+	if rest.Password == "" {
+		errs = append(errs, fmt.Errorf("%srest.password 不能为空, 与服务器的 AdminPassword 一致", prefix))
+	}
+	// RCON 为可选，只用于执行自定义命令
+	if rcon.Address != "" {
+		if _, port, err := net.SplitHostPort(rcon.Address); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("%srcon.address 必须为 host:port 格式, 例如 127.0.0.1:25575", prefix))
+		}
+	}
+	return errs
+}
+
+func isHttpUrl(address string) bool {
+	u, err := url.Parse(address)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -11,9 +11,19 @@ import (
 var db *bbolt.DB
 var once sync.Once
 
+// 额外服务器的数据库，每个服务器使用单独的文件
+var (
+	serverDBs   = make(map[string]*bbolt.DB)
+	serverDBsMu sync.Mutex
+)
+
 func InitDB() *bbolt.DB {
+	return openDB("pst.db")
+}
+
+func openDB(path string) *bbolt.DB {
 	// 打开或创建数据库文件
-	db_, err := bbolt.Open("pst.db", 0600, &bbolt.Options{Timeout: 1 * time.Minute})
+	db_, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 1 * time.Minute})
 	if err != nil {
 		logger.Panic(err)
 	}
@@ -58,6 +68,132 @@ func InitDB() *bbolt.DB {
 		logger.Panic(err)
 	}
 
+	// 创建"player_history"桶
+	// player_history
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("player_history"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"sessions"桶
+	// sessions
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("sessions"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"users"桶
+	// users
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("users"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"audit"桶
+	// audit
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("audit"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"bans"桶
+	// bans
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("bans"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"metrics"桶
+	// metrics
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("metrics"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"metrics_hourly"桶
+	// metrics_hourly
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("metrics_hourly"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"announcements"桶
+	// announcements
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("announcements"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"webhook_deliveries"桶
+	// webhook_deliveries
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("webhook_deliveries"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"guild_history"和"guild_archive"桶
+	// guild_history, guild_archive
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte("guild_history")); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists([]byte("guild_archive"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"global_whitelist"和"global_bans"桶，只使用默认服务器数据库中的
+	// global_whitelist, global_bans
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte("global_whitelist")); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists([]byte("global_bans"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	// 创建"restart"桶
+	// restart
+	err = db_.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte("restart"))
+		return err
+	})
+	if err != nil {
+		logger.Panic(err)
+	}
+
 	return db_
 }
 
@@ -70,3 +206,15 @@ func GetDB() *bbolt.DB {
 	// 返回初始化后的数据库实例
 	return db
 }
+
+// GetServerDB 返回额外服务器的数据库，数据保存在 pst_<id>.db 中，与默认服务器隔离
+func GetServerDB(id string) *bbolt.DB {
+	serverDBsMu.Lock()
+	defer serverDBsMu.Unlock()
+	if db_, ok := serverDBs[id]; ok {
+		return db_
+	}
+	db_ := openDB("pst_" + id + ".db")
+	serverDBs[id] = db_
+	return db_
+}
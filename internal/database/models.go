@@ -71,6 +71,8 @@ type BaseCamp struct {
 }
 
 type Guild struct {
+	// 存档中公会的 GroupId，更换管理员后保持不变
+	GroupId        string         `json:"group_id"`
 	Name           string         `json:"name"`
 	BaseCampLevel  int32          `json:"base_camp_level"`
 	AdminPlayerUid string         `json:"admin_player_uid"`
@@ -110,8 +112,202 @@ type Item struct {
 	StackCount int32  `json:"StackCount"`
 }
 
+type PlayerSnapshot struct {
+	PlayerUid   string           `json:"player_uid"`
+	Nickname    string           `json:"nickname"`
+	Level       int32            `json:"level"`
+	Exp         int64            `json:"exp"`
+	PalCount    int              `json:"pal_count"`
+	StatusPoint map[string]int32 `json:"status_point"`
+	LastOnline  time.Time        `json:"last_online"`
+	SaveTime    time.Time        `json:"save_time"`
+}
+
+type PlayerSession struct {
+	PlayerUid string    `json:"player_uid"`
+	Nickname  string    `json:"nickname"`
+	SteamId   string    `json:"steam_id"`
+	Ip        string    `json:"ip"`
+	StartTime time.Time `json:"start_time"`
+	// 会话未结束时为零值
+	EndTime  time.Time `json:"end_time"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type PlayerPlaytime struct {
+	PlayerUid     string `json:"player_uid"`
+	Nickname      string `json:"nickname"`
+	Online        bool   `json:"online"`
+	Sessions      int    `json:"sessions"`
+	TotalSeconds  int64  `json:"total_seconds"`
+	WeeklySeconds int64  `json:"weekly_seconds"`
+}
+
+type TerseUser struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type User struct {
+	TerseUser
+	PasswordHash string `json:"password_hash"`
+}
+
+type AuditLog struct {
+	Time time.Time `json:"time"`
+	// 使用 web.password 登录时为空
+	Actor  string `json:"actor"`
+	Role   string `json:"role"`
+	Action string `json:"action"`
+	// 操作的目标玩家、备份或用户等
+	Target   string `json:"target"`
+	Params   string `json:"params"`
+	Status   int    `json:"status"`
+	Result   string `json:"result"`
+	ClientIp string `json:"client_ip"`
+	// 操作的服务器 ID，默认服务器为空
+	Server string `json:"server,omitempty"`
+}
+
+// Ban 封禁记录，全局封禁按 SteamId 作用于所有服务器，PlayerUid 为空
+type Ban struct {
+	PlayerUid string    `json:"player_uid"`
+	SteamId   string    `json:"steam_id"`
+	Nickname  string    `json:"nickname"`
+	Reason    string    `json:"reason"`
+	Operator  string    `json:"operator"`
+	CreatedAt time.Time `json:"created_at"`
+	// 永久封禁时为零值
+	ExpiresAt time.Time `json:"expires_at"`
+	// 全局封禁已解除，但这些服务器暂时无法访问，每分钟重试一次，不为空时封禁不再生效
+	PendingUnban []string `json:"pending_unban,omitempty"`
+}
+
+// PlannedRestart 服务器计划中的重启，保存后重启工具时不会丢失手动安排、推迟或取消的重启
+type PlannedRestart struct {
+	Time   time.Time `json:"time"`
+	Manual bool      `json:"manual"`
+	// 计算重启时间时使用的 cron 表达式
+	Cron string `json:"cron"`
+	// 玩家是否已经收到过提醒
+	Notified bool `json:"notified"`
+}
+
+// MetricsPoint 服务器指标的采样点，降采样后为一段时间内的汇总
+type MetricsPoint struct {
+	Time time.Time `json:"time"`
+	// 平均帧率和最低帧率，只统计服务器可以访问时的采样
+	ServerFps       float64 `json:"server_fps"`
+	MinServerFps    int     `json:"min_server_fps"`
+	ServerFrameTime float64 `json:"server_frame_time"`
+	// 最高在线人数
+	CurrentPlayerNum int `json:"current_player_num"`
+	Uptime           int `json:"uptime"`
+	// 采样次数，以及其中服务器可以访问的次数
+	Samples       int `json:"samples"`
+	OnlineSamples int `json:"online_samples"`
+}
+
+type Announcement struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// cron 表达式与间隔秒数二选一
+	Cron     string    `json:"cron"`
+	Interval int       `json:"interval"`
+	Message  string    `json:"message"`
+	Enabled  bool      `json:"enabled"`
+	LastRun  time.Time `json:"last_run"`
+}
+
+type WebhookDelivery struct {
+	Time     time.Time `json:"time"`
+	Webhook  string    `json:"webhook"`
+	Event    string    `json:"event"`
+	Attempts int       `json:"attempts"`
+	Status   int       `json:"status"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+}
+
 type Backup struct {
 	BackupId string    `json:"backup_id"`
 	SaveTime time.Time `json:"save_time"`
 	Path     string    `json:"path"`
-}
\ No newline at end of file
+	// 保存了该备份的存储目标，为空表示旧版本保存在本地的备份
+	Targets []string `json:"targets"`
+	// 备份中每个文件的清单，为空表示旧版本的完整 zip 备份
+	Files []BackupFile `json:"files,omitempty"`
+	// 最近一次校验的结果，Status 为空表示尚未校验
+	Status      string    `json:"status"`
+	VerifyTime  time.Time `json:"verify_time"`
+	VerifyError string    `json:"verify_error,omitempty"`
+}
+
+const (
+	BackupStatusOk     = "ok"
+	BackupStatusBroken = "broken"
+)
+
+type BackupFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Identity 跨服务器的玩家身份，按 SteamID 关联各个服务器中的玩家记录
+type Identity struct {
+	SteamId string `json:"steam_id"`
+	// 最近在线的玩家记录的昵称
+	Nickname   string           `json:"nickname"`
+	LastOnline time.Time        `json:"last_online"`
+	Players    []IdentityPlayer `json:"players"`
+	// 是否在全局白名单和全局封禁列表中
+	Whitelisted bool `json:"whitelisted"`
+	Banned      bool `json:"banned"`
+}
+
+type IdentityPlayer struct {
+	Server     string    `json:"server"`
+	PlayerUid  string    `json:"player_uid"`
+	Nickname   string    `json:"nickname"`
+	Level      int32     `json:"level"`
+	LastOnline time.Time `json:"last_online"`
+}
+
+const (
+	GuildEventCreate      = "create"
+	GuildEventDisband     = "disband"
+	GuildEventJoin        = "join"
+	GuildEventLeave       = "leave"
+	GuildEventAdminChange = "admin_change"
+)
+
+// GuildEvent 存档同步时发现的公会变化
+type GuildEvent struct {
+	Type           string `json:"type"`
+	GroupId        string `json:"group_id"`
+	AdminPlayerUid string `json:"admin_player_uid"`
+	GuildName      string `json:"guild_name"`
+	// 加入、离开的玩家，更换管理员时为新的管理员
+	PlayerUid string    `json:"player_uid,omitempty"`
+	Nickname  string    `json:"nickname,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// ArchivedGuild 已解散的公会，保留解散前最后一次同步的数据
+type ArchivedGuild struct {
+	Guild
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+type GuildStats struct {
+	AdminPlayerUid string `json:"admin_player_uid"`
+	Name           string `json:"name"`
+	MemberCount    int    `json:"member_count"`
+	// 成员等级之和，未同步到的成员不计入
+	TotalLevel    int64 `json:"total_level"`
+	PalCount      int   `json:"pal_count"`
+	BaseCampCount int   `json:"base_camp_count"`
+	BaseCampLevel int32 `json:"base_camp_level"`
+}
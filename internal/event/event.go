@@ -0,0 +1,82 @@
+package event
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+)
+
+const (
+	// 在线玩家列表及位置，每次同步玩家后推送
+	EventPlayers = "players"
+	// 服务器性能指标，每次采集后推送
+	EventMetrics = "metrics"
+	// 玩家信息同步完成
+	EventPlayerSync = "player_sync"
+	// 存档同步完成
+	EventSavSync = "sav_sync"
+)
+
+// 每个订阅者缓冲的事件数，客户端处理不过来时丢弃新事件
+const subscriberBuffer = 64
+
+type Event struct {
+	Type    string      `json:"event"`
+	Time    time.Time   `json:"time"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	// 额外服务器的 ID，默认服务器为空
+	Server string `json:"server,omitempty"`
+	// 接收该事件所需的最低角色，为空时匿名用户也能接收
+	Role auth.Role `json:"-"`
+}
+
+type subscriber struct {
+	ch   chan Event
+	role auth.Role
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = make(map[*subscriber]struct{})
+)
+
+// Subscribe 订阅事件，role 为空表示匿名用户，返回的函数用于取消订阅
+func Subscribe(role auth.Role) (<-chan Event, func()) {
+	sub := &subscriber{
+		ch:   make(chan Event, subscriberBuffer),
+		role: role,
+	}
+	mu.Lock()
+	subscribers[sub] = struct{}{}
+	mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers, sub)
+			mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// Publish 将事件推送给有权限的订阅者，不会阻塞
+func Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for sub := range subscribers {
+		if event.Role != "" && !sub.role.Allows(event.Role) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
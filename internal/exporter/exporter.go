@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+var (
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pst_job_duration_seconds",
+		Help:    "Duration of scheduled jobs.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"job"})
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pst_job_runs_total",
+		Help: "Number of scheduled job runs by status.",
+	}, []string{"job", "status"})
+	lastSavSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pst_last_sav_sync_timestamp_seconds",
+		Help: "Unix time of the last successful sav sync.",
+	}, []string{"server"})
+
+	registry = prometheus.NewRegistry()
+)
+
+func init() {
+	registry.MustRegister(jobDuration, jobRuns, lastSavSync, collector{})
+}
+
+// Handler 返回 Prometheus 格式的指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// SetLastSavSync 记录服务器最后一次成功同步存档的时间
+func SetLastSavSync(server string, t time.Time) {
+	lastSavSync.WithLabelValues(server).Set(float64(t.Unix()))
+}
+
+// Monitor 记录定时任务的执行时间和结果
+type Monitor struct{}
+
+func (Monitor) IncrementJob(_ uuid.UUID, name string, _ []string, status gocron.JobStatus) {
+	jobRuns.WithLabelValues(name, string(status)).Inc()
+}
+
+func (Monitor) RecordJobTiming(startTime, endTime time.Time, _ uuid.UUID, name string, _ []string) {
+	jobDuration.WithLabelValues(name).Observe(endTime.Sub(startTime).Seconds())
+}
+
+// 除任务指标外的指标都带有 server 标签，值为服务器 ID
+var (
+	serverUpDesc        = prometheus.NewDesc("palworld_server_up", "Whether the server REST API is reachable.", []string{"server"}, nil)
+	serverFpsDesc       = prometheus.NewDesc("palworld_server_fps", "Server FPS.", []string{"server"}, nil)
+	serverFrameTimeDesc = prometheus.NewDesc("palworld_server_frame_time_milliseconds", "Server frame time.", []string{"server"}, nil)
+	playersOnlineDesc   = prometheus.NewDesc("palworld_players_online", "Number of online players.", []string{"server"}, nil)
+	playersMaxDesc      = prometheus.NewDesc("palworld_players_max", "Maximum number of players.", []string{"server"}, nil)
+	serverUptimeDesc    = prometheus.NewDesc("palworld_server_uptime_seconds", "Server uptime.", []string{"server"}, nil)
+	serverDaysDesc      = prometheus.NewDesc("palworld_server_days", "In-game days.", []string{"server"}, nil)
+	playerPingDesc      = prometheus.NewDesc("palworld_player_ping", "Ping of online players.", []string{"server", "player_uid", "nickname"}, nil)
+	guildsDesc          = prometheus.NewDesc("palworld_guilds", "Number of guilds in the last synced save.", []string{"server"}, nil)
+	baseCampsDesc       = prometheus.NewDesc("palworld_base_camps", "Number of base camps in the last synced save.", []string{"server"}, nil)
+	lastBackupDesc      = prometheus.NewDesc("pst_last_backup_timestamp_seconds", "Unix time of the last successful backup.", []string{"server"}, nil)
+)
+
+// collector 在每次抓取时读取服务器和数据库中的数据
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serverUpDesc
+	ch <- serverFpsDesc
+	ch <- serverFrameTimeDesc
+	ch <- playersOnlineDesc
+	ch <- playersMaxDesc
+	ch <- serverUptimeDesc
+	ch <- serverDaysDesc
+	ch <- playerPingDesc
+	ch <- guildsDesc
+	ch <- baseCampsDesc
+	ch <- lastBackupDesc
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	for _, srv := range tool.Servers() {
+		collectServer(ch, srv)
+	}
+}
+
+// collectServer 输出单个服务器的指标
+func collectServer(ch chan<- prometheus.Metric, srv *tool.Server) {
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, append([]string{srv.Id}, labels...)...)
+	}
+
+	metrics, err := srv.Metrics()
+	if err != nil {
+		logger.Warnf("获取服务器 %s 指标失败: %v\n", srv.Id, err)
+		gauge(serverUpDesc, 0)
+	} else {
+		gauge(serverUpDesc, 1)
+		gauge(serverFpsDesc, float64(metrics["server_fps"].(int)))
+		gauge(serverFrameTimeDesc, metrics["server_frame_time"].(float64))
+		gauge(playersOnlineDesc, float64(metrics["current_player_num"].(int)))
+		gauge(playersMaxDesc, float64(metrics["max_player_num"].(int)))
+		gauge(serverUptimeDesc, float64(metrics["uptime"].(int)))
+		gauge(serverDaysDesc, float64(metrics["days"].(int)))
+
+		players, err := srv.ShowPlayers()
+		if err == nil {
+			// 刚进入服务器的玩家可能还没有 UID，相同的标签只能输出一次
+			seen := make(map[string]bool, len(players))
+			for _, player := range players {
+				key := player.PlayerUid + "\x00" + player.Nickname
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				gauge(playerPingDesc, player.Ping, player.PlayerUid, player.Nickname)
+			}
+		}
+	}
+
+	db := srv.DB()
+	guilds, err := service.ListGuilds(db)
+	if err == nil {
+		baseCamps := 0
+		for _, guild := range guilds {
+			baseCamps += len(guild.BaseCamp)
+		}
+		gauge(guildsDesc, float64(len(guilds)))
+		gauge(baseCampsDesc, float64(baseCamps))
+	}
+	backups, err := service.ListBackups(db, time.Time{}, time.Time{})
+	if err == nil && len(backups) > 0 {
+		gauge(lastBackupDesc, float64(backups[len(backups)-1].SaveTime.Unix()))
+	}
+}
@@ -0,0 +1,202 @@
+package sav
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf16"
+)
+
+// GUID 为 UE 存档中的 16 字节标识，按存档中的原始字节顺序保存
+type GUID [16]byte
+
+func (g GUID) String() string {
+	b := g
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%04x%08x",
+		uint32(b[3])<<24|uint32(b[2])<<16|uint32(b[1])<<8|uint32(b[0]),
+		uint16(b[7])<<8|uint16(b[6]),
+		uint16(b[5])<<8|uint16(b[4]),
+		uint16(b[0xB])<<8|uint16(b[0xA]),
+		uint16(b[9])<<8|uint16(b[8]),
+		uint32(b[0xF])<<24|uint32(b[0xE])<<16|uint32(b[0xD])<<8|uint32(b[0xC]),
+	)
+}
+
+// Decimal 返回 GUID 第一段的十进制表示，与 REST API 中的 PlayerUid 保持一致
+func (g GUID) Decimal() string {
+	return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(g[0:4])), 10)
+}
+
+func (g GUID) IsZero() bool {
+	return g == GUID{}
+}
+
+// archive 是对 UE FArchive 的只读实现，读取出错后后续读取均返回零值，
+// 调用方只需在合适的位置检查 err
+type archive struct {
+	data []byte
+	pos  int
+	err  error
+
+	// 结构体类型提示，用于 MapProperty 中无法从数据本身得知的结构体类型
+	hints map[string]string
+	// 返回 true 时只保留该路径属性的原始字节，不做解析
+	skip func(path string) bool
+}
+
+func newArchive(data []byte, hints map[string]string, skip func(path string) bool) *archive {
+	return &archive{data: data, hints: hints, skip: skip}
+}
+
+// copy 使用相同的配置读取另一段数据
+func (a *archive) copy(data []byte) *archive {
+	return newArchive(data, a.hints, a.skip)
+}
+
+func (a *archive) fail(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+func (a *archive) eof() bool {
+	return a.pos >= len(a.data)
+}
+
+// capacity 返回切片的预分配容量，count 来自存档本身，按剩余字节数限制，
+// 避免损坏的存档申请过多内存。minSize 为每个元素至少占用的字节数
+func (a *archive) capacity(count, minSize int) int {
+	if count < 0 {
+		return 0
+	}
+	if remaining := (len(a.data) - a.pos) / minSize; count > remaining {
+		return remaining
+	}
+	return count
+}
+
+func (a *archive) read(n int) []byte {
+	if a.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(a.data)-a.pos {
+		a.fail(fmt.Errorf("读取 %d 字节失败(偏移 %d): %w", n, a.pos, io.ErrUnexpectedEOF))
+		return nil
+	}
+	b := a.data[a.pos : a.pos+n]
+	a.pos += n
+	return b
+}
+
+func (a *archive) readToEnd() []byte {
+	return a.read(len(a.data) - a.pos)
+}
+
+func (a *archive) byte() byte {
+	b := a.read(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (a *archive) bool() bool {
+	return a.byte() > 0
+}
+
+func (a *archive) u16() uint16 {
+	b := a.read(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (a *archive) i32() int32 {
+	return int32(a.u32())
+}
+
+func (a *archive) u32() uint32 {
+	b := a.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (a *archive) i64() int64 {
+	return int64(a.u64())
+}
+
+func (a *archive) u64() uint64 {
+	b := a.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (a *archive) f32() float32 {
+	return math.Float32frombits(a.u32())
+}
+
+func (a *archive) f64() float64 {
+	return math.Float64frombits(a.u64())
+}
+
+func (a *archive) guid() GUID {
+	var g GUID
+	copy(g[:], a.read(16))
+	return g
+}
+
+func (a *archive) optionalGuid() *GUID {
+	if !a.bool() {
+		return nil
+	}
+	g := a.guid()
+	return &g
+}
+
+// fstring 读取 UE 的 FString，长度为负时为 UTF-16LE 编码
+func (a *archive) fstring() string {
+	size := a.i32()
+	if size == 0 || a.err != nil {
+		return ""
+	}
+	if size < 0 {
+		b := a.read(int(-size) * 2)
+		if len(b) < 2 {
+			return ""
+		}
+		b = b[:len(b)-2]
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(b[i*2:])
+		}
+		return string(utf16.Decode(u))
+	}
+	b := a.read(int(size))
+	if len(b) < 1 {
+		return ""
+	}
+	return string(b[:len(b)-1])
+}
+
+func (a *archive) ftransform() Transform {
+	return Transform{
+		Rotation:    a.quat(),
+		Translation: a.vector(),
+		Scale3D:     a.vector(),
+	}
+}
+
+func (a *archive) vector() Vector {
+	return Vector{X: a.f64(), Y: a.f64(), Z: a.f64()}
+}
+
+func (a *archive) quat() Quat {
+	return Quat{X: a.f64(), Y: a.f64(), Z: a.f64(), W: a.f64()}
+}
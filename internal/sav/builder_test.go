@@ -0,0 +1,147 @@
+package sav
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+)
+
+// builder 按存档格式写入测试数据
+type builder struct {
+	bytes.Buffer
+}
+
+func (b *builder) u16(v uint16) *builder {
+	binary.Write(&b.Buffer, binary.LittleEndian, v)
+	return b
+}
+
+func (b *builder) i32(v int32) *builder {
+	binary.Write(&b.Buffer, binary.LittleEndian, v)
+	return b
+}
+
+func (b *builder) u32(v uint32) *builder {
+	binary.Write(&b.Buffer, binary.LittleEndian, v)
+	return b
+}
+
+func (b *builder) i64(v int64) *builder {
+	binary.Write(&b.Buffer, binary.LittleEndian, v)
+	return b
+}
+
+func (b *builder) u64(v uint64) *builder {
+	binary.Write(&b.Buffer, binary.LittleEndian, v)
+	return b
+}
+
+func (b *builder) byte(v byte) *builder {
+	b.WriteByte(v)
+	return b
+}
+
+func (b *builder) guid(g GUID) *builder {
+	b.Write(g[:])
+	return b
+}
+
+func (b *builder) fstring(s string) *builder {
+	if s == "" {
+		return b.i32(0)
+	}
+	b.i32(int32(len(s) + 1))
+	b.WriteString(s)
+	return b.byte(0)
+}
+
+func (b *builder) none() *builder {
+	return b.fstring("None")
+}
+
+// prop 写入属性头部和数据，head 为类型相关的头部字段
+func (b *builder) prop(name, typeName string, head func(h *builder), body []byte) *builder {
+	b.fstring(name).fstring(typeName).u64(uint64(len(body)))
+	if head != nil {
+		head(b)
+	}
+	b.Write(body)
+	return b
+}
+
+func (b *builder) intProp(name string, v int32) *builder {
+	return b.prop(name, "IntProperty", noGuid, new(builder).i32(v).Bytes())
+}
+
+func (b *builder) strProp(name, v string) *builder {
+	return b.prop(name, "StrProperty", noGuid, new(builder).fstring(v).Bytes())
+}
+
+func (b *builder) enumProp(name, enum, v string) *builder {
+	return b.prop(name, "EnumProperty", func(h *builder) { h.fstring(enum).byte(0) }, new(builder).fstring(v).Bytes())
+}
+
+func (b *builder) boolProp(name string, v bool) *builder {
+	var value byte
+	if v {
+		value = 1
+	}
+	return b.prop(name, "BoolProperty", func(h *builder) { h.byte(value).byte(0) }, nil)
+}
+
+func (b *builder) structProp(name, structType string, body []byte) *builder {
+	return b.prop(name, "StructProperty", func(h *builder) { h.fstring(structType).guid(GUID{}).byte(0) }, body)
+}
+
+func (b *builder) bytesProp(name string, data []byte) *builder {
+	body := new(builder).u32(uint32(len(data)))
+	body.Write(data)
+	return b.prop(name, "ArrayProperty", func(h *builder) { h.fstring("ByteProperty").byte(0) }, body.Bytes())
+}
+
+func (b *builder) mapProp(name, keyType, valueType string, entries ...[]byte) *builder {
+	body := new(builder).u32(0).u32(uint32(len(entries)))
+	for _, entry := range entries {
+		body.Write(entry)
+	}
+	return b.prop(name, "MapProperty", func(h *builder) { h.fstring(keyType).fstring(valueType).byte(0) }, body.Bytes())
+}
+
+func noGuid(h *builder) {
+	h.byte(0)
+}
+
+// gvas 写入 GVAS 头部，props 为顶层属性(不含结尾的 None)
+func gvas(props []byte) []byte {
+	b := new(builder)
+	b.i32(gvasMagic).i32(3).i32(522).i32(1008)
+	b.u16(5).u16(1).u16(1).u32(0).fstring("++UE5+Release-5.1")
+	b.i32(3).u32(1).guid(GUID{1}).i32(1)
+	b.fstring("/Script/Pal.PalWorldSaveGame")
+	b.Write(props)
+	b.none()
+	return b.Bytes()
+}
+
+// compress 按 PlZ 格式压缩 GVAS 数据，saveType 为 0x31 或 0x32
+func compress(data []byte, saveType byte) []byte {
+	payload := deflate(data)
+	compressedLen := len(payload)
+	if saveType == saveTypeDoubleZlib {
+		payload = deflate(payload)
+	}
+	b := new(builder)
+	b.u32(uint32(len(data))).u32(uint32(compressedLen))
+	b.WriteString(magicPlZ)
+	b.byte(saveType)
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
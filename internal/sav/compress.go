@@ -0,0 +1,139 @@
+package sav
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// 使用 zlib 压缩的存档
+	magicPlZ = "PlZ"
+	// 使用 Oodle 压缩的存档
+	magicPlM = "PlM"
+	// 部分存档在头部前额外包含一个 CNK 块
+	magicCNK = "CNK"
+)
+
+const (
+	saveTypeNone       byte = 0x30
+	saveTypeZlib       byte = 0x31
+	saveTypeDoubleZlib byte = 0x32
+)
+
+var (
+	ErrNotSaveFile       = errors.New("不是有效的 Palworld 压缩存档")
+	ErrOodleNotSupported = errors.New("暂不支持 Oodle 压缩(PlM)的存档, 目前只能读取 zlib 压缩(PlZ)的存档")
+)
+
+// header Palworld 压缩存档的头部
+type header struct {
+	uncompressedLen uint32
+	compressedLen   uint32
+	magic           string
+	saveType        byte
+	// 压缩数据的起始偏移
+	offset int
+}
+
+// parseHeader 读取并校验存档头部
+func parseHeader(data []byte) (header, error) {
+	if len(data) < 12 {
+		return header{}, ErrNotSaveFile
+	}
+
+	// 读取头部: 解压后长度、压缩长度、魔数和存档类型
+	h := header{
+		uncompressedLen: binary.LittleEndian.Uint32(data[0:4]),
+		compressedLen:   binary.LittleEndian.Uint32(data[4:8]),
+		magic:           string(data[8:11]),
+		saveType:        data[11],
+		offset:          12,
+	}
+
+	// 跳过 CNK 块，真正的头部紧随其后
+	if h.magic == magicCNK {
+		if len(data) < 24 {
+			return header{}, ErrNotSaveFile
+		}
+		h.uncompressedLen = binary.LittleEndian.Uint32(data[12:16])
+		h.compressedLen = binary.LittleEndian.Uint32(data[16:20])
+		h.magic = string(data[20:23])
+		h.saveType = data[23]
+		h.offset = 24
+	}
+
+	if h.magic == magicPlM {
+		return header{}, ErrOodleNotSupported
+	}
+	if h.magic != magicPlZ {
+		if h.uncompressedLen == 0 && h.compressedLen == 0 {
+			return header{}, fmt.Errorf("%w: 存档中存在过多的空字节，文件可能已损坏", ErrNotSaveFile)
+		}
+		return header{}, fmt.Errorf("%w: 魔数为 %q", ErrNotSaveFile, h.magic)
+	}
+
+	switch h.saveType {
+	case saveTypeZlib, saveTypeDoubleZlib:
+	case saveTypeNone:
+		return header{}, fmt.Errorf("不支持的压缩类型: 0x%x", h.saveType)
+	default:
+		return header{}, fmt.Errorf("未知的存档类型: 0x%x", h.saveType)
+	}
+	return h, nil
+}
+
+// CheckFormat 只读取存档头部，判断能否解压该存档
+func CheckFormat(data []byte) error {
+	_, err := parseHeader(data)
+	return err
+}
+
+// Decompress 将 .sav 文件内容解压为 GVAS 数据
+func Decompress(data []byte) ([]byte, error) {
+	h, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	payload := data[h.offset:]
+	if h.saveType == saveTypeZlib && int(h.compressedLen) != len(payload) {
+		return nil, fmt.Errorf("压缩长度不正确: %d", h.compressedLen)
+	}
+
+	uncompressed, err := inflate(payload)
+	if err != nil {
+		return nil, err
+	}
+	// 0x32 类型的存档经过了两次 zlib 压缩
+	if h.saveType == saveTypeDoubleZlib {
+		if int(h.compressedLen) != len(uncompressed) {
+			return nil, fmt.Errorf("压缩长度不正确: %d", h.compressedLen)
+		}
+		uncompressed, err = inflate(uncompressed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if int(h.uncompressedLen) != len(uncompressed) {
+		return nil, fmt.Errorf("解压后长度不正确: %d", h.uncompressedLen)
+	}
+	return uncompressed, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("存档已损坏: %w", err)
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("存档已损坏: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,92 @@
+package sav
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	data := gvas(new(builder).intProp("Version", 1).Bytes())
+
+	for _, saveType := range []byte{saveTypeZlib, saveTypeDoubleZlib} {
+		out, err := Decompress(compress(data, saveType))
+		if err != nil {
+			t.Fatalf("0x%x: %v", saveType, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("0x%x: 解压结果不一致", saveType)
+		}
+	}
+
+	// 带 CNK 块的存档
+	save := compress(data, saveTypeZlib)
+	cnk := new(builder).u32(0).u32(0)
+	cnk.WriteString(magicCNK)
+	cnk.byte(saveTypeZlib)
+	cnk.Write(save)
+	out, err := Decompress(cnk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("CNK: 解压结果不一致")
+	}
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	data := gvas(new(builder).intProp("Version", 1).Bytes())
+	save := compress(data, saveTypeZlib)
+
+	plm := append([]byte(nil), save...)
+	copy(plm[8:11], magicPlM)
+
+	corrupted := append([]byte(nil), save...)
+	for i := 14; i < len(corrupted); i++ {
+		corrupted[i] ^= 0xff
+	}
+
+	wrongLen := append([]byte(nil), save...)
+	wrongLen[0]++
+
+	cases := map[string]struct {
+		data []byte
+		want error
+	}{
+		"empty":      {nil, ErrNotSaveFile},
+		"header":     {save[:11], ErrNotSaveFile},
+		"zeros":      {make([]byte, 64), ErrNotSaveFile},
+		"magic":      {append([]byte("\x00\x00\x00\x00\x00\x00\x00\x00GVS1"), save[12:]...), ErrNotSaveFile},
+		"oodle":      {plm, ErrOodleNotSupported},
+		"truncated":  {save[:len(save)-8], nil},
+		"corrupted":  {corrupted, nil},
+		"length":     {wrongLen, nil},
+		"cnk header": {append([]byte("\x00\x00\x00\x00\x00\x00\x00\x00CNK1"), save[:4]...), ErrNotSaveFile},
+	}
+	for name, c := range cases {
+		_, err := Decompress(c.data)
+		if err == nil {
+			t.Errorf("%s: 期望返回错误", name)
+			continue
+		}
+		if c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("%s: 期望 %v, 实际为 %v", name, c.want, err)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	save := compress(gvas(nil), saveTypeZlib)
+	if err := CheckFormat(save[:12]); err != nil {
+		t.Fatal(err)
+	}
+
+	plm := append([]byte(nil), save[:12]...)
+	copy(plm[8:11], magicPlM)
+	if err := CheckFormat(plm); !errors.Is(err, ErrOodleNotSupported) {
+		t.Fatalf("期望 ErrOodleNotSupported, 实际为 %v", err)
+	}
+	if err := CheckFormat(save[:8]); !errors.Is(err, ErrNotSaveFile) {
+		t.Fatalf("期望 ErrNotSaveFile, 实际为 %v", err)
+	}
+}
@@ -0,0 +1,494 @@
+package sav
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const gvasMagic = 0x53415647
+
+// Property 为存档中的一个属性
+//
+// Value 的类型取决于 Type:
+//   - StructProperty: Properties、GUID、Vector、Quat、uint64(DateTime) 等
+//   - ArrayProperty: ByteProperty 数组为 []byte，其余为 []interface{}
+//   - MapProperty: []MapEntry
+//   - 无法解析或被跳过的属性: Raw
+type Property struct {
+	Type string
+	// StructProperty 的结构体类型、ArrayProperty 的元素类型、
+	// EnumProperty/ByteProperty 的枚举类型
+	SubType string
+	Value   interface{}
+}
+
+type Properties map[string]*Property
+
+type MapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Raw 为未解析的属性原始数据
+type Raw []byte
+
+type Vector struct {
+	X, Y, Z float64
+}
+
+type Quat struct {
+	X, Y, Z, W float64
+}
+
+type Transform struct {
+	Rotation    Quat
+	Translation Vector
+	Scale3D     Vector
+}
+
+type GvasHeader struct {
+	SaveGameVersion       int32
+	PackageFileVersionUE4 int32
+	PackageFileVersionUE5 int32
+	EngineVersion         string
+	SaveGameClassName     string
+}
+
+type GvasFile struct {
+	Header     GvasHeader
+	Properties Properties
+}
+
+// typeHints 与 palworld-save-tools 中的 PALWORLD_TYPE_HINTS 对应
+var typeHints = map[string]string{
+	".worldSaveData.CharacterContainerSaveData.Key":                                                                  "StructProperty",
+	".worldSaveData.CharacterSaveParameterMap.Key":                                                                   "StructProperty",
+	".worldSaveData.CharacterSaveParameterMap.Value":                                                                 "StructProperty",
+	".worldSaveData.FoliageGridSaveDataMap.Key":                                                                      "StructProperty",
+	".worldSaveData.FoliageGridSaveDataMap.Value.ModelMap.Value":                                                     "StructProperty",
+	".worldSaveData.FoliageGridSaveDataMap.Value.ModelMap.Value.InstanceDataMap.Key":                                 "StructProperty",
+	".worldSaveData.FoliageGridSaveDataMap.Value.ModelMap.Value.InstanceDataMap.Value":                               "StructProperty",
+	".worldSaveData.FoliageGridSaveDataMap.Value":                                                                    "StructProperty",
+	".worldSaveData.ItemContainerSaveData.Key":                                                                       "StructProperty",
+	".worldSaveData.MapObjectSaveData.MapObjectSaveData.ConcreteModel.ModuleMap.Value":                               "StructProperty",
+	".worldSaveData.MapObjectSaveData.MapObjectSaveData.Model.EffectMap.Value":                                       "StructProperty",
+	".worldSaveData.MapObjectSpawnerInStageSaveData.Key":                                                             "StructProperty",
+	".worldSaveData.MapObjectSpawnerInStageSaveData.Value":                                                           "StructProperty",
+	".worldSaveData.MapObjectSpawnerInStageSaveData.Value.SpawnerDataMapByLevelObjectInstanceId.Key":                 "Guid",
+	".worldSaveData.MapObjectSpawnerInStageSaveData.Value.SpawnerDataMapByLevelObjectInstanceId.Value":               "StructProperty",
+	".worldSaveData.WorkSaveData.WorkSaveData.WorkAssignMap.Value":                                                   "StructProperty",
+	".worldSaveData.BaseCampSaveData.Key":                                                                            "Guid",
+	".worldSaveData.BaseCampSaveData.Value":                                                                          "StructProperty",
+	".worldSaveData.BaseCampSaveData.Value.ModuleMap.Value":                                                          "StructProperty",
+	".worldSaveData.ItemContainerSaveData.Value":                                                                     "StructProperty",
+	".worldSaveData.CharacterContainerSaveData.Value":                                                                "StructProperty",
+	".worldSaveData.GroupSaveDataMap.Key":                                                                            "Guid",
+	".worldSaveData.GroupSaveDataMap.Value":                                                                          "StructProperty",
+	".worldSaveData.EnemyCampSaveData.EnemyCampStatusMap.Value":                                                      "StructProperty",
+	".worldSaveData.InvaderSaveData.Key":                                                                             "Guid",
+	".worldSaveData.InvaderSaveData.Value":                                                                           "StructProperty",
+	".worldSaveData.OilrigSaveData.OilrigMap.Value":                                                                  "StructProperty",
+	".worldSaveData.SupplySaveData.SupplyInfos.Key":                                                                  "Guid",
+	".worldSaveData.SupplySaveData.SupplyInfos.Value":                                                                "StructProperty",
+	".worldSaveData.MapObjectSpawnerInStageSaveData.Value.SpawnerDataMapByLevelObjectInstanceId.Value.ItemMap.Value": "StructProperty",
+}
+
+// ReadGvas 解析解压后的 GVAS 数据，skip 返回 true 的属性只保留原始字节
+func ReadGvas(data []byte, skip func(path string) bool) (*GvasFile, error) {
+	a := newArchive(data, typeHints, skip)
+
+	header, err := readHeader(a)
+	if err != nil {
+		return nil, err
+	}
+	props := a.properties("")
+	if a.err != nil {
+		return nil, fmt.Errorf("解析 GVAS 属性失败: %w", a.err)
+	}
+	return &GvasFile{Header: header, Properties: props}, nil
+}
+
+func readHeader(a *archive) (GvasHeader, error) {
+	var h GvasHeader
+	if a.i32() != gvasMagic {
+		return h, errors.New("无效的 GVAS 魔数")
+	}
+	h.SaveGameVersion = a.i32()
+	if h.SaveGameVersion != 3 {
+		return h, fmt.Errorf("期望存档版本为 3，实际为 %d", h.SaveGameVersion)
+	}
+	h.PackageFileVersionUE4 = a.i32()
+	h.PackageFileVersionUE5 = a.i32()
+	major, minor, patch := a.u16(), a.u16(), a.u16()
+	changelist := a.u32()
+	branch := a.fstring()
+	h.EngineVersion = fmt.Sprintf("%d.%d.%d-%d+%s", major, minor, patch, changelist, branch)
+	if format := a.i32(); format != 3 {
+		return h, fmt.Errorf("期望自定义版本格式为 3，实际为 %d", format)
+	}
+	// 自定义版本列表
+	count := a.u32()
+	for i := uint32(0); i < count && a.err == nil; i++ {
+		a.guid()
+		a.i32()
+	}
+	h.SaveGameClassName = a.fstring()
+	if a.err != nil {
+		return h, fmt.Errorf("解析 GVAS 头部失败: %w", a.err)
+	}
+	return h, nil
+}
+
+func (a *archive) properties(path string) Properties {
+	props := make(Properties)
+	for a.err == nil {
+		name := a.fstring()
+		if name == "None" || a.err != nil {
+			break
+		}
+		typeName := a.fstring()
+		size := a.u64()
+		props[name] = a.property(typeName, size, path+"."+name)
+	}
+	return props
+}
+
+// property 读取属性头部后，按 size 截取属性数据单独解析，
+// 这样即使遇到无法识别的类型也不会影响后续属性的读取
+func (a *archive) property(typeName string, size uint64, path string) *Property {
+	p := &Property{Type: typeName}
+	var keyType, valueType string
+
+	switch typeName {
+	case "StructProperty":
+		p.SubType = a.fstring()
+		a.guid()
+		a.optionalGuid()
+	case "ArrayProperty", "SetProperty":
+		p.SubType = a.fstring()
+		a.optionalGuid()
+	case "MapProperty":
+		keyType = a.fstring()
+		valueType = a.fstring()
+		a.optionalGuid()
+	case "EnumProperty", "ByteProperty":
+		p.SubType = a.fstring()
+		a.optionalGuid()
+	case "BoolProperty":
+		// 布尔值保存在头部中，没有数据部分
+		p.Value = a.bool()
+		a.optionalGuid()
+		return p
+	default:
+		a.optionalGuid()
+	}
+
+	if size > uint64(len(a.data)) {
+		a.fail(fmt.Errorf("属性 %s 的长度无效: %d", path, size))
+		return p
+	}
+	body := a.read(int(size))
+	if a.err != nil {
+		return p
+	}
+	if a.skip != nil && a.skip(path) {
+		p.Value = Raw(body)
+		return p
+	}
+
+	sub := a.copy(body)
+	var value interface{}
+	switch typeName {
+	case "StructProperty":
+		value = sub.structValue(p.SubType, path)
+	case "ArrayProperty":
+		value = sub.arrayValue(p.SubType, path)
+	case "MapProperty":
+		value = sub.mapValue(keyType, valueType, path)
+	case "ByteProperty":
+		if p.SubType == "None" {
+			value = sub.byte()
+		} else {
+			value = sub.fstring()
+		}
+	default:
+		value = sub.scalar(typeName, path)
+	}
+	if sub.err != nil {
+		value = Raw(body)
+	}
+	p.Value = value
+	return p
+}
+
+// scalar 读取简单类型的值
+func (a *archive) scalar(typeName, path string) interface{} {
+	switch typeName {
+	case "IntProperty", "FixedPoint64Property":
+		return a.i32()
+	case "Int8Property":
+		return int8(a.byte())
+	case "Int16Property":
+		return int16(a.u16())
+	case "UInt16Property":
+		return a.u16()
+	case "UInt32Property":
+		return a.u32()
+	case "Int64Property":
+		return a.i64()
+	case "UInt64Property":
+		return a.u64()
+	case "FloatProperty":
+		return a.f32()
+	case "DoubleProperty":
+		return a.f64()
+	case "StrProperty", "NameProperty", "EnumProperty":
+		return a.fstring()
+	case "BoolProperty":
+		return a.bool()
+	case "ByteProperty":
+		return a.byte()
+	}
+	a.fail(fmt.Errorf("未知的属性类型: %s (%s)", typeName, path))
+	return nil
+}
+
+func (a *archive) structValue(structType, path string) interface{} {
+	switch structType {
+	case "Vector", "Rotator":
+		return a.vector()
+	case "Quat":
+		return a.quat()
+	case "DateTime":
+		return a.u64()
+	case "Timespan":
+		return a.i64()
+	case "Guid":
+		return a.guid()
+	case "LinearColor":
+		return [4]float32{a.f32(), a.f32(), a.f32(), a.f32()}
+	case "Vector2D":
+		return [2]float64{a.f64(), a.f64()}
+	case "IntPoint":
+		return [2]int32{a.i32(), a.i32()}
+	}
+	return a.properties(path)
+}
+
+func (a *archive) arrayValue(arrayType, path string) interface{} {
+	count := int(a.u32())
+	if a.err != nil {
+		return nil
+	}
+
+	switch arrayType {
+	case "ByteProperty":
+		return a.read(count)
+	case "StructProperty":
+		propName := a.fstring()
+		a.fstring()
+		a.u64()
+		typeName := a.fstring()
+		a.guid()
+		a.optionalGuid()
+		values := make([]interface{}, 0, a.capacity(count, 1))
+		for i := 0; i < count && a.err == nil; i++ {
+			values = append(values, a.structValue(typeName, path+"."+propName))
+		}
+		return values
+	case "Guid":
+		values := make([]interface{}, 0, a.capacity(count, 16))
+		for i := 0; i < count && a.err == nil; i++ {
+			values = append(values, a.guid())
+		}
+		return values
+	}
+
+	values := make([]interface{}, 0, a.capacity(count, 1))
+	for i := 0; i < count && a.err == nil; i++ {
+		values = append(values, a.scalar(arrayType, path))
+	}
+	return values
+}
+
+func (a *archive) mapValue(keyType, valueType, path string) interface{} {
+	// 待删除元素数量，总是为 0
+	a.u32()
+	count := int(a.u32())
+
+	keyPath := path + ".Key"
+	valuePath := path + ".Value"
+	keyStructType := a.hint(keyPath, "Guid")
+	valueStructType := a.hint(valuePath, "StructProperty")
+
+	entries := make([]MapEntry, 0)
+	for i := 0; i < count && a.err == nil; i++ {
+		key := a.mapItem(keyType, keyStructType, keyPath)
+		value := a.mapItem(valueType, valueStructType, valuePath)
+		entries = append(entries, MapEntry{Key: key, Value: value})
+	}
+	return entries
+}
+
+func (a *archive) mapItem(typeName, structType, path string) interface{} {
+	if typeName == "StructProperty" {
+		return a.structValue(structType, path)
+	}
+	return a.scalar(typeName, path)
+}
+
+func (a *archive) hint(path, def string) string {
+	if t, ok := a.hints[path]; ok {
+		return t
+	}
+	return def
+}
+
+// Get 返回指定名称的属性，不存在时返回 nil
+func (p Properties) Get(name string) *Property {
+	if p == nil {
+		return nil
+	}
+	return p[name]
+}
+
+// Struct 返回结构体属性中的子属性
+func (p Properties) Struct(name string) Properties {
+	prop := p.Get(name)
+	if prop == nil {
+		return nil
+	}
+	v, _ := prop.Value.(Properties)
+	return v
+}
+
+// Int 以整数形式返回属性值，兼容 IntProperty、ByteProperty、Int64Property 等类型
+func (p Properties) Int(name string, def int64) int64 {
+	prop := p.Get(name)
+	if prop == nil {
+		return def
+	}
+	if v, ok := toInt(prop.Value); ok {
+		return v
+	}
+	return def
+}
+
+func (p Properties) Float(name string, def float64) float64 {
+	prop := p.Get(name)
+	if prop == nil {
+		return def
+	}
+	switch v := prop.Value.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	if v, ok := toInt(prop.Value); ok {
+		return float64(v)
+	}
+	return def
+}
+
+func (p Properties) String(name string) string {
+	prop := p.Get(name)
+	if prop == nil {
+		return ""
+	}
+	v, _ := prop.Value.(string)
+	return v
+}
+
+func (p Properties) Bool(name string) bool {
+	prop := p.Get(name)
+	if prop == nil {
+		return false
+	}
+	v, _ := prop.Value.(bool)
+	return v
+}
+
+func (p Properties) GUID(name string) (GUID, bool) {
+	prop := p.Get(name)
+	if prop == nil {
+		return GUID{}, false
+	}
+	v, ok := prop.Value.(GUID)
+	return v, ok
+}
+
+func (p Properties) Array(name string) []interface{} {
+	prop := p.Get(name)
+	if prop == nil {
+		return nil
+	}
+	v, _ := prop.Value.([]interface{})
+	return v
+}
+
+func (p Properties) Bytes(name string) []byte {
+	prop := p.Get(name)
+	if prop == nil {
+		return nil
+	}
+	switch v := prop.Value.(type) {
+	case []byte:
+		return v
+	case Raw:
+		return v
+	}
+	return nil
+}
+
+func (p Properties) Map(name string) []MapEntry {
+	prop := p.Get(name)
+	if prop == nil {
+		return nil
+	}
+	v, _ := prop.Value.([]MapEntry)
+	return v
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int8:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), true
+	case float32:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// skipWorldProperties 返回只解析 worldSaveData 中指定子属性的过滤函数，
+// 其余如地图对象、植被等体积庞大的数据直接跳过
+func skipWorldProperties(names ...string) func(path string) bool {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	return func(path string) bool {
+		rest, ok := strings.CutPrefix(path, ".worldSaveData.")
+		if !ok || strings.Contains(rest, ".") {
+			return false
+		}
+		return !keep[rest]
+	}
+}
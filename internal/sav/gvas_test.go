@@ -0,0 +1,94 @@
+package sav
+
+import (
+	"testing"
+)
+
+func TestReadGvas(t *testing.T) {
+	props := new(builder).
+		intProp("Version", 7).
+		strProp("Name", "pst").
+		boolProp("Enabled", true).
+		structProp("Location", "Vector", new(builder).u64(0).u64(0).u64(0).Bytes()).
+		Bytes()
+
+	file, err := ReadGvas(gvas(props), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Header.SaveGameClassName != "/Script/Pal.PalWorldSaveGame" {
+		t.Fatalf("存档类名为 %q", file.Header.SaveGameClassName)
+	}
+	if v := file.Properties.Int("Version", 0); v != 7 {
+		t.Fatalf("Version 为 %d", v)
+	}
+	if v := file.Properties.String("Name"); v != "pst" {
+		t.Fatalf("Name 为 %q", v)
+	}
+	if !file.Properties.Bool("Enabled") {
+		t.Fatal("Enabled 应为 true")
+	}
+	if _, ok := file.Properties.Get("Location").Value.(Vector); !ok {
+		t.Fatalf("Location 类型为 %T", file.Properties.Get("Location").Value)
+	}
+}
+
+func TestReadGvasTruncated(t *testing.T) {
+	data := gvas(testWorld(testGuild()))
+	if _, err := ReadGvas(data, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 任意位置截断都应返回错误而不是 panic
+	for n := 0; n < len(data); n++ {
+		if _, err := ReadGvas(data[:n], nil); err == nil {
+			t.Fatalf("截断为 %d 字节时期望返回错误", n)
+		}
+	}
+}
+
+func TestReadGvasCorrupted(t *testing.T) {
+	data := gvas(testWorld(testGuild()))
+	// 逐字节破坏数据，只要求不 panic
+	for i := range data {
+		corrupted := append([]byte(nil), data...)
+		corrupted[i] ^= 0xff
+		ReadGvas(corrupted, nil)
+	}
+}
+
+func TestArrayCountBounded(t *testing.T) {
+	// 声明了约 40 亿个 GUID 的数组，实际只有 16 字节数据
+	body := new(builder).u32(0xfffffff0).guid(GUID{1}).Bytes()
+	props := new(builder).
+		prop("Ids", "ArrayProperty", func(h *builder) { h.fstring("Guid").byte(0) }, body).
+		intProp("Version", 7).
+		Bytes()
+
+	file, err := ReadGvas(gvas(props), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 数组本身无法解析，保留原始字节，后续属性不受影响
+	if _, ok := file.Properties.Get("Ids").Value.(Raw); !ok {
+		t.Fatalf("Ids 类型为 %T", file.Properties.Get("Ids").Value)
+	}
+	if v := file.Properties.Int("Version", 0); v != 7 {
+		t.Fatalf("Version 为 %d", v)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	a := newArchive(make([]byte, 64), nil, nil)
+	a.pos = 16
+	cases := []struct{ count, minSize, want int }{
+		{2, 16, 2},
+		{1 << 30, 16, 3},
+		{1 << 30, 1, 48},
+		{-1, 1, 0},
+	}
+	for _, c := range cases {
+		if got := a.capacity(c.count, c.minSize); got != c.want {
+			t.Errorf("capacity(%d, %d) = %d, 期望 %d", c.count, c.minSize, got, c.want)
+		}
+	}
+}
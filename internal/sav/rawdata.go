@@ -0,0 +1,127 @@
+package sav
+
+import "fmt"
+
+// 以下为存档中以 RawData 字节数组形式保存的自定义结构
+
+type groupPlayer struct {
+	PlayerUid          GUID
+	LastOnlineRealTime int64
+	PlayerName         string
+}
+
+type guildData struct {
+	GroupId        GUID
+	GroupName      string
+	BaseIds        []GUID
+	BaseCampLevel  int32
+	GuildName      string
+	AdminPlayerUid GUID
+	Players        []groupPlayer
+}
+
+type baseCampData struct {
+	Id        GUID
+	Name      string
+	State     byte
+	Transform Transform
+	AreaRange float32
+}
+
+type itemSlotData struct {
+	SlotIndex    uint32
+	StackCount   uint32
+	ItemStaticId string
+}
+
+// decodeCharacter 解析 CharacterSaveParameterMap.Value.RawData
+func decodeCharacter(parent *archive, raw []byte) (Properties, error) {
+	a := parent.copy(raw)
+	object := a.properties("")
+	// 之后为 4 个未知字节和所属组 ID
+	a.read(4)
+	a.guid()
+	if a.err != nil {
+		return nil, fmt.Errorf("解析角色数据失败: %w", a.err)
+	}
+	return object, nil
+}
+
+// decodeGuild 解析类型为 EPalGroupType::Guild 的 GroupSaveDataMap.Value.RawData
+func decodeGuild(parent *archive, raw []byte) (*guildData, error) {
+	a := parent.copy(raw)
+	g := &guildData{}
+	g.GroupId = a.guid()
+	g.GroupName = a.fstring()
+	// individual_character_handle_ids
+	count := a.u32()
+	for i := uint32(0); i < count && a.err == nil; i++ {
+		a.guid()
+		a.guid()
+	}
+	// org_type
+	a.byte()
+	g.BaseIds = a.guids()
+	g.BaseCampLevel = a.i32()
+	// map_object_instance_ids_base_camp_points
+	a.guids()
+	g.GuildName = a.fstring()
+	// 16 个未知字节
+	a.read(16)
+	g.AdminPlayerUid = a.guid()
+	playerCount := a.i32()
+	for i := int32(0); i < playerCount && a.err == nil; i++ {
+		g.Players = append(g.Players, groupPlayer{
+			PlayerUid:          a.guid(),
+			LastOnlineRealTime: a.i64(),
+			PlayerName:         a.fstring(),
+		})
+	}
+	if a.err != nil {
+		return nil, fmt.Errorf("解析公会数据失败: %w", a.err)
+	}
+	return g, nil
+}
+
+// decodeBaseCamp 解析 BaseCampSaveData.Value.RawData
+func decodeBaseCamp(parent *archive, raw []byte) (*baseCampData, error) {
+	a := parent.copy(raw)
+	b := &baseCampData{
+		Id:        a.guid(),
+		Name:      a.fstring(),
+		State:     a.byte(),
+		Transform: a.ftransform(),
+		AreaRange: a.f32(),
+	}
+	// 其后的 group_id_belong_to、fast_travel_local_transform 等字段在不同版本中有差异，这里不再读取
+	if a.err != nil {
+		return nil, fmt.Errorf("解析据点数据失败: %w", a.err)
+	}
+	return b, nil
+}
+
+// decodeItemSlot 解析 ItemContainerSaveData.Value.Slots.Slots.RawData，空槽位返回 nil
+func decodeItemSlot(parent *archive, raw []byte) (*itemSlotData, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	a := parent.copy(raw)
+	s := &itemSlotData{
+		SlotIndex:    a.u32(),
+		StackCount:   a.u32(),
+		ItemStaticId: a.fstring(),
+	}
+	if a.err != nil {
+		return nil, fmt.Errorf("解析物品数据失败: %w", a.err)
+	}
+	return s, nil
+}
+
+func (a *archive) guids() []GUID {
+	count := a.u32()
+	ids := make([]GUID, 0)
+	for i := uint32(0); i < count && a.err == nil; i++ {
+		ids = append(ids, a.guid())
+	}
+	return ids
+}
@@ -0,0 +1,387 @@
+package sav
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+)
+
+// 玩家背包中需要导出的容器
+var playerContainers = []string{
+	"CommonContainerId",
+	"DropSlotContainerId",
+	"EssentialContainerId",
+	"FoodEquipContainerId",
+	"PlayerEquipArmorContainerId",
+	"WeaponLoadOutContainerId",
+}
+
+type world struct {
+	archive *archive
+	wsd     Properties
+	// RealDateTimeTicks 与 Level.sav 修改时间，用于换算最后在线时间
+	ticks    int64
+	fileTime float64
+	// 物品容器 ID 到容器数据的映射
+	containers map[GUID]Properties
+}
+
+// ErrIncompleteGuilds 部分公会无法解析，返回的公会列表不完整
+var ErrIncompleteGuilds = errors.New("部分公会无法解析")
+
+// Decode 解析 Level.sav 及同目录下的 Players/*.sav，返回玩家与公会信息。
+// 部分公会无法解析时仍返回解析出的数据，错误为 ErrIncompleteGuilds
+func Decode(levelPath string) ([]database.Player, []database.Guild, error) {
+	stat, err := os.Stat(levelPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	gvas, err := readSaveFile(levelPath, skipWorldProperties(
+		"CharacterSaveParameterMap",
+		"GroupSaveDataMap",
+		"BaseCampSaveData",
+		"ItemContainerSaveData",
+		"GameTimeSaveData",
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wsd := gvas.Properties.Struct("worldSaveData")
+	if wsd == nil {
+		return nil, nil, errors.New("存档中缺少 worldSaveData")
+	}
+	w := &world{
+		archive:  newArchive(nil, typeHints, nil),
+		wsd:      wsd,
+		ticks:    wsd.Struct("GameTimeSaveData").Int("RealDateTimeTicks", 0),
+		fileTime: float64(stat.ModTime().UnixNano()) / 1e9,
+	}
+	w.indexContainers()
+
+	players := w.players(filepath.Join(filepath.Dir(levelPath), "Players"))
+	guilds, lastOnline, failed := w.guilds()
+
+	// 使用公会中记录的最后在线时间补全玩家信息
+	for i := range players {
+		if t, ok := lastOnline[players[i].PlayerUid]; ok {
+			players[i].SaveLastOnline = t
+		}
+	}
+	if failed > 0 {
+		return players, guilds, ErrIncompleteGuilds
+	}
+	return players, guilds, nil
+}
+
+func readSaveFile(path string, skip func(path string) bool) (*GvasFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := Decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	return ReadGvas(raw, skip)
+}
+
+func (w *world) indexContainers() {
+	w.containers = make(map[GUID]Properties)
+	for _, entry := range w.wsd.Map("ItemContainerSaveData") {
+		key, _ := entry.Key.(Properties)
+		value, _ := entry.Value.(Properties)
+		if id, ok := key.GUID("ID"); ok && value != nil {
+			w.containers[id] = value
+		}
+	}
+}
+
+func (w *world) players(playerDir string) []database.Player {
+	players := make(map[string]*database.Player)
+	type ownedPal struct {
+		owner string
+		pal   *database.Pal
+	}
+	pals := make([]ownedPal, 0)
+
+	for _, entry := range w.wsd.Map("CharacterSaveParameterMap") {
+		key, _ := entry.Key.(Properties)
+		value, _ := entry.Value.(Properties)
+		raw := value.Bytes("RawData")
+		if raw == nil {
+			continue
+		}
+		object, err := decodeCharacter(w.archive, raw)
+		if err != nil {
+			logger.Warnf("%v\n", err)
+			continue
+		}
+		c := object.Struct("SaveParameter")
+		if c == nil {
+			continue
+		}
+
+		if c.Bool("IsPlayer") {
+			uid, _ := key.GUID("PlayerUId")
+			player := newPlayer(uid, c)
+			// 同一玩家存在多条记录时保留等级较高的一条
+			if existing, ok := players[player.PlayerUid]; ok && existing.Level >= player.Level {
+				continue
+			}
+			player.Items = w.playerItems(uid, playerDir)
+			players[player.PlayerUid] = player
+			continue
+		}
+
+		owner, ok := c.GUID("OwnerPlayerUId")
+		if !ok {
+			continue
+		}
+		pals = append(pals, ownedPal{owner: owner.Decimal(), pal: newPal(c)})
+	}
+
+	for _, p := range pals {
+		if player, ok := players[p.owner]; ok {
+			player.Pals = append(player.Pals, p.pal)
+		}
+	}
+
+	result := make([]database.Player, 0, len(players))
+	for _, player := range players {
+		result = append(result, *player)
+	}
+	// 按等级降序排列
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Level != result[j].Level {
+			return result[i].Level > result[j].Level
+		}
+		return result[i].PlayerUid < result[j].PlayerUid
+	})
+	return result
+}
+
+func newPlayer(uid GUID, c Properties) *database.Player {
+	player := &database.Player{}
+	player.PlayerUid = uid.Decimal()
+	player.Nickname = c.String("NickName")
+	player.Level = int32(c.Int("Level", 1))
+	player.Exp = c.Int("Exp", 0)
+	player.Hp = fixedPoint(c, "HP")
+	player.MaxHp = fixedPoint(c, "MaxHP")
+	player.ShieldHp = fixedPoint(c, "ShieldHP")
+	player.ShieldMaxHp = fixedPoint(c, "ShieldMaxHP")
+	player.MaxStatusPoint = int32(fixedPoint(c, "MaxSP"))
+	player.StatusPoint = make(map[string]int32)
+	for _, v := range c.Array("GotStatusPointList") {
+		s, _ := v.(Properties)
+		player.StatusPoint[s.String("StatusName")] = int32(s.Int("StatusPoint", 0))
+	}
+	player.FullStomach = math.Round(c.Float("FullStomach", 0)*100) / 100
+	player.Pals = make([]*database.Pal, 0)
+	return player
+}
+
+func newPal(c Properties) *database.Pal {
+	pal := &database.Pal{
+		Nickname:       c.String("NickName"),
+		Level:          int32(c.Int("Level", 1)),
+		Exp:            c.Int("Exp", 0),
+		Hp:             fixedPoint(c, "HP"),
+		MaxHp:          fixedPoint(c, "MaxHP"),
+		Gender:         "Unknow",
+		IsLucky:        c.Bool("IsRarePal"),
+		Type:           "Unknow",
+		Workspeed:      int32(c.Int("CraftSpeed", 0)),
+		Melee:          int32(c.Int("Talent_Melee", 0)),
+		Ranged:         int32(c.Int("Talent_Shot", 0)),
+		Defense:        int32(c.Int("Talent_Defense", 0)),
+		Rank:           int32(c.Int("Rank", 1)),
+		RankAttack:     int32(c.Int("Rank_Attack", 0)),
+		RankDefence:    int32(c.Int("Rank_Defence", 0)),
+		RankCraftspeed: int32(c.Int("Rank_CraftSpeed", 0)),
+		Skills:         make([]string, 0),
+	}
+	if gender := c.String("Gender"); gender != "" {
+		parts := strings.Split(gender, "::")
+		pal.Gender = parts[len(parts)-1]
+	}
+	if typeName := c.String("CharacterID"); typeName != "" {
+		upper := strings.ToUpper(typeName)
+		if strings.HasPrefix(upper, "BOSS_") {
+			upper = strings.TrimPrefix(upper, "BOSS_")
+			pal.IsBoss = !pal.IsLucky
+		}
+		pal.IsTower = strings.HasPrefix(upper, "GYM_")
+		pal.Type = typeName
+	}
+	for _, v := range c.Array("PassiveSkillList") {
+		if skill, ok := v.(string); ok {
+			pal.Skills = append(pal.Skills, skill)
+		}
+	}
+	return pal
+}
+
+// fixedPoint 读取 FixedPoint64 结构中的 Value
+func fixedPoint(c Properties, name string) int64 {
+	return c.Struct(name).Int("Value", 0)
+}
+
+func emptyItems() *database.Items {
+	return &database.Items{
+		CommonContainerId:           make([]*database.Item, 0),
+		DropSlotContainerId:         make([]*database.Item, 0),
+		EssentialContainerId:        make([]*database.Item, 0),
+		FoodEquipContainerId:        make([]*database.Item, 0),
+		PlayerEquipArmorContainerId: make([]*database.Item, 0),
+		WeaponLoadOutContainerId:    make([]*database.Item, 0),
+	}
+}
+
+func (w *world) playerItems(uid GUID, playerDir string) *database.Items {
+	items := emptyItems()
+
+	// 玩家存档文件名为去掉横线后的大写 UID
+	fileName := strings.ToUpper(strings.ReplaceAll(uid.String(), "-", "")) + ".sav"
+	playerFile := filepath.Join(playerDir, fileName)
+	if _, err := os.Stat(playerFile); err != nil {
+		return items
+	}
+	gvas, err := readSaveFile(playerFile, nil)
+	if err != nil {
+		logger.Errorf("玩家 Sav 文件已损坏: %s: %v\n", fileName, err)
+		return items
+	}
+	inventory := gvas.Properties.Struct("SaveData").Struct("InventoryInfo")
+	if inventory == nil {
+		return items
+	}
+
+	targets := map[string]*[]*database.Item{
+		"CommonContainerId":           &items.CommonContainerId,
+		"DropSlotContainerId":         &items.DropSlotContainerId,
+		"EssentialContainerId":        &items.EssentialContainerId,
+		"FoodEquipContainerId":        &items.FoodEquipContainerId,
+		"PlayerEquipArmorContainerId": &items.PlayerEquipArmorContainerId,
+		"WeaponLoadOutContainerId":    &items.WeaponLoadOutContainerId,
+	}
+	for _, name := range playerContainers {
+		id, ok := inventory.Struct(name).GUID("ID")
+		if !ok {
+			continue
+		}
+		container, ok := w.containers[id]
+		if !ok {
+			continue
+		}
+		*targets[name] = w.containerItems(container)
+	}
+	return items
+}
+
+func (w *world) containerItems(container Properties) []*database.Item {
+	items := make([]*database.Item, 0)
+	for _, v := range container.Array("Slots") {
+		slot, _ := v.(Properties)
+		s, err := decodeItemSlot(w.archive, slot.Bytes("RawData"))
+		if err != nil {
+			logger.Warnf("%v\n", err)
+			continue
+		}
+		if s == nil || strings.ToLower(s.ItemStaticId) == "none" {
+			continue
+		}
+		items = append(items, &database.Item{
+			SlotIndex:  int32(s.SlotIndex),
+			ItemId:     strings.ToLower(s.ItemStaticId),
+			StackCount: int32(s.StackCount),
+		})
+	}
+	return items
+}
+
+// guilds 返回公会列表、公会中记录的玩家最后在线时间以及无法解析的公会数量
+func (w *world) guilds() ([]database.Guild, map[string]string, int) {
+	camps := w.baseCamps()
+
+	guilds := make([]database.Guild, 0)
+	lastOnline := make(map[string]string)
+	failed := 0
+	for _, entry := range w.wsd.Map("GroupSaveDataMap") {
+		value, _ := entry.Value.(Properties)
+		if value.String("GroupType") != "EPalGroupType::Guild" {
+			continue
+		}
+		data, err := decodeGuild(w.archive, value.Bytes("RawData"))
+		if err != nil {
+			logger.Warnf("%v\n", err)
+			failed++
+			continue
+		}
+
+		g := database.Guild{
+			GroupId:        data.GroupId.String(),
+			Name:           data.GuildName,
+			BaseCampLevel:  data.BaseCampLevel,
+			AdminPlayerUid: data.AdminPlayerUid.Decimal(),
+			Players:        make([]*database.GuildPlayer, 0, len(data.Players)),
+			BaseCamp:       make([]database.BaseCamp, 0),
+		}
+		for _, p := range data.Players {
+			uid := p.PlayerUid.Decimal()
+			g.Players = append(g.Players, &database.GuildPlayer{
+				PlayerUid: uid,
+				Nickname:  p.PlayerName,
+			})
+			if p.LastOnlineRealTime != 0 {
+				lastOnline[uid] = w.tickToTime(p.LastOnlineRealTime)
+			}
+		}
+		for _, id := range data.BaseIds {
+			if camp, ok := camps[id.Decimal()]; ok {
+				g.BaseCamp = append(g.BaseCamp, camp)
+			}
+		}
+		guilds = append(guilds, g)
+	}
+
+	// 按据点等级降序排列
+	sort.SliceStable(guilds, func(i, j int) bool {
+		return guilds[i].BaseCampLevel > guilds[j].BaseCampLevel
+	})
+	return guilds, lastOnline, failed
+}
+
+func (w *world) baseCamps() map[string]database.BaseCamp {
+	camps := make(map[string]database.BaseCamp)
+	for _, entry := range w.wsd.Map("BaseCampSaveData") {
+		value, _ := entry.Value.(Properties)
+		data, err := decodeBaseCamp(w.archive, value.Bytes("RawData"))
+		if err != nil {
+			logger.Warnf("%v\n", err)
+			continue
+		}
+		id := data.Id.Decimal()
+		camps[id] = database.BaseCamp{
+			Id:        id,
+			Area:      float64(data.AreaRange),
+			LocationX: data.Transform.Translation.X,
+			LocationY: data.Transform.Translation.Y,
+		}
+	}
+	return camps
+}
+
+// tickToTime 将游戏内的 RealDateTime 刻度换算为 RFC3339 格式的时间
+func (w *world) tickToTime(tick int64) string {
+	ts := w.fileTime + float64(tick-w.ticks)/1e7
+	return time.Unix(int64(math.Floor(ts)), 0).UTC().Format("2006-01-02T15:04:05Z")
+}
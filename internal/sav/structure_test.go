@@ -0,0 +1,149 @@
+package sav
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	testPlayerUid = GUID{0x39, 0x30}
+	testGroupId   = GUID{0x10, 0x20, 0x30}
+)
+
+// testCharacter 返回 CharacterSaveParameterMap 的一项
+func testCharacter(instance byte, param []byte) []byte {
+	key := new(builder).
+		structProp("PlayerUId", "Guid", new(builder).guid(testPlayerUid).Bytes()).
+		structProp("InstanceId", "Guid", new(builder).guid(GUID{instance}).Bytes()).
+		none()
+
+	raw := new(builder).structProp("SaveParameter", "PalIndividualCharacterSaveParameter", param).none()
+	raw.u32(0).guid(GUID{})
+	value := new(builder).bytesProp("RawData", raw.Bytes()).none()
+
+	key.Write(value.Bytes())
+	return key.Bytes()
+}
+
+// testGuild 返回只有一名成员的公会 RawData
+func testGuild() []byte {
+	b := new(builder)
+	b.guid(testGroupId).fstring("Guild")
+	// individual_character_handle_ids
+	b.u32(0)
+	b.byte(0)
+	// base_ids
+	b.u32(0)
+	b.i32(3)
+	// map_object_instance_ids_base_camp_points
+	b.u32(0)
+	b.fstring("测试公会")
+	b.Write(make([]byte, 16))
+	b.guid(testPlayerUid)
+	b.i32(1).guid(testPlayerUid).i64(0).fstring("Alice")
+	return b.Bytes()
+}
+
+// testWorld 返回包含一名玩家、一只帕鲁以及指定公会的 worldSaveData 属性
+func testWorld(guilds ...[]byte) []byte {
+	player := new(builder).
+		boolProp("IsPlayer", true).
+		strProp("NickName", "Alice").
+		intProp("Level", 12).
+		none().Bytes()
+	pal := new(builder).
+		structProp("OwnerPlayerUId", "Guid", new(builder).guid(testPlayerUid).Bytes()).
+		strProp("CharacterID", "SheepBall").
+		intProp("Level", 5).
+		none().Bytes()
+
+	groups := make([][]byte, 0, len(guilds))
+	for _, raw := range guilds {
+		entry := new(builder).guid(testGroupId)
+		entry.enumProp("GroupType", "EPalGroupType", "EPalGroupType::Guild").
+			bytesProp("RawData", raw).
+			none()
+		groups = append(groups, entry.Bytes())
+	}
+
+	wsd := new(builder).
+		mapProp("CharacterSaveParameterMap", "StructProperty", "StructProperty", testCharacter(1, player), testCharacter(2, pal)).
+		mapProp("GroupSaveDataMap", "StructProperty", "StructProperty", groups...).
+		none()
+	return new(builder).structProp("worldSaveData", "PalWorldSaveData", wsd.Bytes()).Bytes()
+}
+
+func writeLevel(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Level.sav")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDecode(t *testing.T) {
+	path := writeLevel(t, compress(gvas(testWorld(testGuild())), saveTypeDoubleZlib))
+
+	players, guilds, err := Decode(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 1 {
+		t.Fatalf("玩家数量为 %d", len(players))
+	}
+	player := players[0]
+	if player.PlayerUid != "12345" || player.Nickname != "Alice" || player.Level != 12 {
+		t.Fatalf("玩家信息不正确: %+v", player)
+	}
+	if len(player.Pals) != 1 || player.Pals[0].Type != "SheepBall" || player.Pals[0].Level != 5 {
+		t.Fatalf("帕鲁信息不正确: %+v", player.Pals)
+	}
+
+	if len(guilds) != 1 {
+		t.Fatalf("公会数量为 %d", len(guilds))
+	}
+	guild := guilds[0]
+	if guild.Name != "测试公会" || guild.BaseCampLevel != 3 || guild.AdminPlayerUid != "12345" {
+		t.Fatalf("公会信息不正确: %+v", guild)
+	}
+	if len(guild.Players) != 1 || guild.Players[0].Nickname != "Alice" {
+		t.Fatalf("公会成员不正确: %+v", guild.Players)
+	}
+}
+
+func TestDecodeIncompleteGuilds(t *testing.T) {
+	guild := testGuild()
+	path := writeLevel(t, compress(gvas(testWorld(guild, guild[:20])), saveTypeZlib))
+
+	players, guilds, err := Decode(path)
+	if !errors.Is(err, ErrIncompleteGuilds) {
+		t.Fatalf("期望 ErrIncompleteGuilds, 实际为 %v", err)
+	}
+	if len(players) != 1 || len(guilds) != 1 {
+		t.Fatalf("玩家数量为 %d, 公会数量为 %d", len(players), len(guilds))
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	save := compress(gvas(testWorld(testGuild())), saveTypeZlib)
+
+	plm := append([]byte(nil), save...)
+	copy(plm[8:11], magicPlM)
+
+	cases := map[string][]byte{
+		"truncated": save[:len(save)/2],
+		"oodle":     plm,
+		"no world":  compress(gvas(new(builder).intProp("Version", 1).Bytes()), saveTypeZlib),
+	}
+	for name, data := range cases {
+		if _, _, err := Decode(writeLevel(t, data)); err == nil {
+			t.Errorf("%s: 期望返回错误", name)
+		}
+	}
+	if _, _, err := Decode(filepath.Join(t.TempDir(), "Level.sav")); err == nil {
+		t.Error("文件不存在时期望返回错误")
+	}
+}
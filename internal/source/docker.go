@@ -3,11 +3,13 @@ package source
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/pkg/stdcopy"
@@ -15,6 +17,7 @@ import (
 	"github.com/qycnet/palworld-server-tool-main/internal/system"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/qycnet/palworld-server-tool-main/internal/logger"
 )
@@ -42,6 +45,13 @@ func CopyFromContainer(containerID, remotePath, way string) (string, error) {
 	}
 	defer cli.Close()
 
+	// 容器已停止时在临时容器中读取
+	containerID, cleanup, err := fsContainer(cli, containerID)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
 	// 取得Level.sav所在目录
 	// 执行find命令查找Level.sav所在的目录
 	findCmd := []string{"sh", "-c", fmt.Sprintf("find %s -maxdepth 4 -path '*/backup/*' -prune -o -name 'Level.sav' -print | xargs dirname", remotePath)}
@@ -180,3 +190,214 @@ func ParseDockerAddress(address string) (containerID, filePath string, err error
 	// 返回 containerID, filePath 和 nil
 	return containerID, filePath, nil
 }
+
+// CopyToContainer 将 srcDir 中的存档文件替换容器中 remotePath 下的存档目录。
+// 写入时容器必须停止，运行中的容器（例如被重启策略重新启动）会先停止，写入后再启动
+func CopyToContainer(containerID, remotePath, srcDir string) error {
+	logger.Infof("写入 savDir 至 %s\n", remotePath)
+
+	cli, err := getDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	running := info.State != nil && info.State.Running
+	if running {
+		if err = cli.ContainerStop(ctx, containerID, container.StopOptions{}); err != nil {
+			return errors.New("无法停止容器: " + err.Error())
+		}
+	}
+
+	target, cleanup, err := fsContainer(cli, containerID)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// 取得Level.sav所在目录
+	findCmd := []string{"sh", "-c", fmt.Sprintf("find %s -maxdepth 4 -path '*/backup/*' -prune -o -name 'Level.sav' -print | xargs dirname", remotePath)}
+	savDir, err := execCommand(target, findCmd, cli)
+	if err != nil {
+		return err
+	}
+	savDir = strings.TrimSpace(savDir)
+	if savDir == "" {
+		return errors.New("在容器的卷中找不到包含Level.sav的目录")
+	}
+
+	// 打包后复制到容器中，由 docker 负责解压
+	var buf bytes.Buffer
+	if err = system.TarGzDir(srcDir, &buf); err != nil {
+		return err
+	}
+	// 删除原有的存档文件，避免备份中没有的 Players/*.sav 残留
+	clearCmd := []string{"sh", "-c", fmt.Sprintf("cd \"%s\" && rm -f ./*.sav && rm -rf ./Players", savDir)}
+	if _, err = execCommand(target, clearCmd, cli); err != nil {
+		return err
+	}
+	if err = cli.CopyToContainer(ctx, target, savDir, &buf, types.CopyToContainerOptions{}); err != nil {
+		return err
+	}
+	// 复制的文件属于 pst 的用户，改为存档目录的所有者，否则服务器无法写入
+	chownCmd := []string{"sh", "-c", fmt.Sprintf("chown -R \"$(stat -c %%u:%%g \"%s\")\" \"%s\"", savDir, savDir)}
+	if _, err = execCommand(target, chownCmd, cli); err != nil {
+		return err
+	}
+
+	if running {
+		return cli.ContainerStart(ctx, containerID, container.StartOptions{})
+	}
+	return nil
+}
+
+// fsContainer 返回可以执行命令读写存档的容器。容器已停止时无法执行命令，
+// 使用相同的镜像创建一个挂载了该容器所有卷的临时容器，cleanup 删除临时容器
+func fsContainer(cli *client.Client, containerID string) (string, func(), error) {
+	ctx := context.Background()
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.State != nil && info.State.Running {
+		return containerID, func() {}, nil
+	}
+
+	resp, err := cli.ContainerCreate(ctx, &container.Config{
+		Image:      info.Image,
+		User:       info.Config.User,
+		Entrypoint: []string{"sleep"},
+		Cmd:        []string{"3600"},
+	}, &container.HostConfig{VolumesFrom: []string{info.ID}}, nil, nil, "")
+	if err != nil {
+		return "", nil, errors.New("无法创建临时容器: " + err.Error())
+	}
+	cleanup := func() {
+		if err := cli.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true}); err != nil {
+			logger.Errorf("无法删除临时容器 %s: %v\n", resp.ID, err)
+		}
+	}
+	if err = cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		cleanup()
+		return "", nil, errors.New("无法启动临时容器: " + err.Error())
+	}
+	return resp.ID, cleanup, nil
+}
+
+// StartContainer 启动容器
+func StartContainer(containerID string) error {
+	cli, err := getDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	return cli.ContainerStart(context.Background(), containerID, container.StartOptions{})
+}
+
+// StopContainer 停止容器，超时未退出时由 docker 强制结束
+func StopContainer(containerID string) error {
+	cli, err := getDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	return cli.ContainerStop(context.Background(), containerID, container.StopOptions{})
+}
+
+// RestartContainer 重启容器
+func RestartContainer(containerID string) error {
+	cli, err := getDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	return cli.ContainerRestart(context.Background(), containerID, container.StopOptions{})
+}
+
+// ContainerUsage 读取容器的 CPU 和内存使用情况
+func ContainerUsage(containerID string) (ResourceUsage, error) {
+	cli, err := getDockerClient()
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	defer cli.Close()
+
+	// stream 为 false 时 docker 会采样两次，precpu_stats 才有数据
+	resp, err := cli.ContainerStats(context.Background(), containerID, false)
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	defer resp.Body.Close()
+	var stats types.StatsJSON
+	if err = json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return ResourceUsage{}, err
+	}
+
+	usage := ResourceUsage{
+		MemoryUsage: stats.MemoryStats.Usage,
+		MemoryLimit: stats.MemoryStats.Limit,
+	}
+	// 与 docker stats 一致，不计算可回收的文件缓存，cgroup v1 和 v2 的字段名不同
+	if cache, ok := stats.MemoryStats.Stats["total_inactive_file"]; ok && cache < usage.MemoryUsage {
+		usage.MemoryUsage -= cache
+	} else if cache, ok := stats.MemoryStats.Stats["inactive_file"]; ok && cache < usage.MemoryUsage {
+		usage.MemoryUsage -= cache
+	}
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		usage.CpuPercent = cpuDelta / systemDelta * cpus * 100
+	}
+	return usage, nil
+}
+
+// ContainerLogs 读取容器日志，tail 为 0 时返回全部日志，follow 为 true 时持续输出直到 ctx 取消
+func ContainerLogs(ctx context.Context, containerID string, tail int, follow bool) (io.ReadCloser, error) {
+	cli, err := getDockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		cli.Close()
+		return nil, err
+	}
+	options := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Tail:       "all",
+	}
+	if tail > 0 {
+		options.Tail = strconv.Itoa(tail)
+	}
+	logs, err := cli.ContainerLogs(ctx, containerID, options)
+	if err != nil {
+		cli.Close()
+		return nil, err
+	}
+
+	// 使用 TTY 的容器输出原始内容，否则需要拆分 stdout 和 stderr
+	reader, writer := io.Pipe()
+	go func() {
+		defer cli.Close()
+		defer logs.Close()
+		if info.Config != nil && info.Config.Tty {
+			_, err = io.Copy(writer, logs)
+		} else {
+			_, err = stdcopy.StdCopy(writer, writer, logs)
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader, nil
+}
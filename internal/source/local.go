@@ -70,3 +70,39 @@ func CopyFromLocal(src, way string) (string, error) {
 	distLevelPath := filepath.Join(tempDir, "Level.sav")
 	return distLevelPath, nil
 }
+
+// CopyToLocal 将 srcDir 中的存档文件写回 dst 对应的存档目录，先删除原有的存档文件，
+// 避免备份中没有的 Players/*.sav 残留在存档目录中
+func CopyToLocal(srcDir, dst string) error {
+	// 获得Level.sav所在目录
+	isDir, err := system.CheckIsDir(dst)
+	if err != nil {
+		return err
+	}
+	savDir := filepath.Dir(dst)
+	if isDir {
+		savDir, err = system.GetSavDir(dst)
+		if err != nil {
+			return errors.New("error 查找Level.sav错误: \n" + err.Error())
+		}
+	}
+
+	if err = clearSavDir(savDir); err != nil {
+		return err
+	}
+	return system.CopyDir(srcDir, savDir)
+}
+
+// clearSavDir 删除存档目录中的 .sav 文件和 Players 目录，保留 backup 等其他文件
+func clearSavDir(savDir string) error {
+	files, err := filepath.Glob(filepath.Join(savDir, "*.sav"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = os.Remove(file); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(filepath.Join(savDir, "Players"))
+}
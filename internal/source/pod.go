@@ -3,6 +3,7 @@ package source
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,7 +15,11 @@ import (
 	"github.com/google/uuid"
 	"github.com/qycnet/palworld-server-tool-main/internal/logger"
 	"github.com/qycnet/palworld-server-tool-main/internal/system"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -249,3 +254,349 @@ func ParseK8sAddress(address string) (namespace, pod, container, filePath string
 	filePath = parts[1]
 	return namespace, pod, container, filePath, nil
 }
+
+// CopyToPod 将 srcDir 中的存档文件替换 Pod 中 remotePath 下的存档目录。
+// 服务器关闭后 Pod 会被重启，写入时会与运行中的服务器冲突，因此先将所属的 Deployment 或
+// StatefulSet 缩容到 0，在挂载了相同存储卷的临时 Pod 中写入，完成后恢复副本数
+func CopyToPod(namespace, podName, container, remotePath, srcDir string) error {
+	logger.Infof("写入 savDir 至 %s:%s\n", container, remotePath)
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return errors.New("获取集群内配置时出错: " + err.Error())
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return errors.New("获取客户端集时出错: " + err.Error())
+	}
+	if namespace == "" {
+		namespace, err = getCurrentNamespace()
+		if err != nil {
+			return errors.New("获取当前命名空间时出错: " + err.Error())
+		}
+	}
+	if container == "" {
+		return ErrContainerEmpty
+	}
+
+	ctx := context.Background()
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	helper, err := restoreHelperPod(pod, container)
+	if err != nil {
+		return err
+	}
+	scaler, err := podScaler(ctx, clientset, pod)
+	if err != nil {
+		return err
+	}
+	replicas, err := scaler.scale(ctx, 0)
+	if err != nil {
+		return errors.New("无法缩容 " + scaler.name + ": " + err.Error())
+	}
+	// 删除了原有的存档后写入失败时不再启动服务器，避免服务器创建新的存档
+	cleared := false
+	defer func() {
+		if cleared {
+			return
+		}
+		if _, err := scaler.scale(context.Background(), replicas); err != nil {
+			logger.Errorf("无法恢复 %s 的副本数: %v\n", scaler.name, err)
+		}
+	}()
+	if err = waitForPod(ctx, clientset, namespace, podName, func(p *corev1.Pod, err error) bool {
+		return apierrors.IsNotFound(err)
+	}); err != nil {
+		return errors.New("等待 Pod 停止时出错: " + err.Error())
+	}
+
+	helper, err = clientset.CoreV1().Pods(namespace).Create(ctx, helper, metav1.CreateOptions{})
+	if err != nil {
+		return errors.New("无法创建临时 Pod: " + err.Error())
+	}
+	defer func() {
+		if err := clientset.CoreV1().Pods(namespace).Delete(context.Background(), helper.Name, metav1.DeleteOptions{}); err != nil {
+			logger.Errorf("无法删除临时 Pod %s: %v\n", helper.Name, err)
+		}
+	}()
+	if err = waitForPod(ctx, clientset, namespace, helper.Name, func(p *corev1.Pod, err error) bool {
+		return err == nil && p.Status.Phase == corev1.PodRunning
+	}); err != nil {
+		return errors.New("等待临时 Pod 启动时出错: " + err.Error())
+	}
+	podName, container = helper.Name, helper.Spec.Containers[0].Name
+
+	// 取得Level.sav所在目录
+	findCmd := []string{"sh", "-c", fmt.Sprintf("find %s -maxdepth 4 -path '*/backup/*' -prune -o -name 'Level.sav' -print | xargs dirname", remotePath)}
+	savDir, err := execPodCommand(clientset, config, namespace, podName, container, findCmd)
+	if err != nil {
+		return errors.New("执行查找命令时出错: " + err.Error())
+	}
+	savDir = strings.TrimSpace(savDir)
+	if savDir == "" {
+		return errors.New("包含 Level.sav 的目录在 Pod 的存储卷中找不到")
+	}
+
+	// 打包后通过标准输入传给 Pod 中的 tar 解压
+	var buf bytes.Buffer
+	if err = system.TarGzDir(srcDir, &buf); err != nil {
+		return err
+	}
+	// 删除原有的存档文件，避免备份中没有的 Players/*.sav 残留，解压后改为存档目录的所有者
+	tarCmd := []string{"sh", "-c", fmt.Sprintf("cd \"%[1]s\" && owner=\"$(stat -c %%u:%%g .)\" && rm -f ./*.sav && rm -rf ./Players && tar xzf - -C \"%[1]s\" && chown -R \"$owner\" \"%[1]s\"", savDir)}
+	cleared = true
+	req := clientset.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Command:   tarCmd,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    true,
+			TTY:       false,
+			Container: container,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	streamCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	var stderr bytes.Buffer
+	err = executor.StreamWithContext(streamCtx, remotecommand.StreamOptions{
+		Stdin:  &buf,
+		Stdout: io.Discard,
+		Stderr: &stderr,
+	})
+	if err == nil && stderr.Len() > 0 {
+		err = errors.New(stderr.String())
+	}
+	if err != nil {
+		return fmt.Errorf("执行解压命令时出错, %s 保持缩容到 0: %v", scaler.name, err)
+	}
+
+	cleared = false
+	return nil
+}
+
+// restoreHelperPod 返回与 Pod 使用相同镜像、存储卷和节点的临时 Pod，只运行 sleep
+func restoreHelperPod(pod *corev1.Pod, container string) (*corev1.Pod, error) {
+	var spec *corev1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == container {
+			spec = &pod.Spec.Containers[i]
+		}
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("Pod 中没有容器 %s", container)
+	}
+	mounted := make(map[string]bool, len(spec.VolumeMounts))
+	for _, mount := range spec.VolumeMounts {
+		mounted[mount.Name] = true
+	}
+	volumes := make([]corev1.Volume, 0, len(mounted))
+	for _, volume := range pod.Spec.Volumes {
+		if mounted[volume.Name] {
+			volumes = append(volumes, volume)
+		}
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pod.Name + "-pst-restore-",
+			Namespace:    pod.Namespace,
+		},
+		Spec: corev1.PodSpec{
+			// ReadWriteOnce 的存储卷只能挂载在同一节点上
+			NodeName:         pod.Spec.NodeName,
+			RestartPolicy:    corev1.RestartPolicyNever,
+			SecurityContext:  pod.Spec.SecurityContext,
+			ImagePullSecrets: pod.Spec.ImagePullSecrets,
+			Volumes:          volumes,
+			Containers: []corev1.Container{{
+				Name:            "restore",
+				Image:           spec.Image,
+				Command:         []string{"sleep", "3600"},
+				VolumeMounts:    spec.VolumeMounts,
+				SecurityContext: spec.SecurityContext,
+			}},
+		},
+	}, nil
+}
+
+// scaler 修改 Deployment 或 StatefulSet 的副本数
+type scaler struct {
+	// 用于日志的名称，例如 StatefulSet palworld
+	name   string
+	object string
+	get    func(ctx context.Context, name string, opts metav1.GetOptions) (*autoscalingv1.Scale, error)
+	update func(ctx context.Context, name string, scale *autoscalingv1.Scale, opts metav1.UpdateOptions) (*autoscalingv1.Scale, error)
+}
+
+// scale 修改副本数，返回修改前的副本数
+func (s scaler) scale(ctx context.Context, replicas int32) (int32, error) {
+	current, err := s.get(ctx, s.object, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	previous := current.Spec.Replicas
+	current.Spec.Replicas = replicas
+	if _, err = s.update(ctx, s.object, current, metav1.UpdateOptions{}); err != nil {
+		return 0, err
+	}
+	return previous, nil
+}
+
+// podScaler 返回 Pod 所属的 Deployment 或 StatefulSet
+func podScaler(ctx context.Context, clientset *kubernetes.Clientset, pod *corev1.Pod) (scaler, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner != nil && owner.Kind == "ReplicaSet" {
+		rs, err := clientset.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return scaler{}, err
+		}
+		owner = metav1.GetControllerOf(rs)
+	}
+	if owner != nil && owner.Kind == "Deployment" {
+		deployments := clientset.AppsV1().Deployments(pod.Namespace)
+		return scaler{name: "Deployment " + owner.Name, object: owner.Name, get: deployments.GetScale, update: deployments.UpdateScale}, nil
+	}
+	if owner != nil && owner.Kind == "StatefulSet" {
+		statefulSets := clientset.AppsV1().StatefulSets(pod.Namespace)
+		return scaler{name: "StatefulSet " + owner.Name, object: owner.Name, get: statefulSets.GetScale, update: statefulSets.UpdateScale}, nil
+	}
+	return scaler{}, errors.New("Pod 不属于 Deployment 或 StatefulSet, 无法停止服务器后恢复存档")
+}
+
+// waitForPod 等待 Pod 满足条件，最多等待 5 分钟
+func waitForPod(ctx context.Context, clientset *kubernetes.Clientset, namespace, podName string, done func(pod *corev1.Pod, err error) bool) error {
+	deadline := time.Now().Add(5 * time.Minute)
+	for time.Now().Before(deadline) {
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if done(pod, err) {
+			return nil
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return errors.New("等待超时")
+}
+
+var ErrPodNoController = errors.New("Pod 不属于 Deployment 或 StatefulSet 等控制器，删除后不会重建")
+
+// getK8sClient 创建集群内的客户端，namespace 为空时使用当前命名空间
+func getK8sClient(namespace string) (*kubernetes.Clientset, string, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, "", errors.New("获取集群内配置时出错: " + err.Error())
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, "", errors.New("获取客户端集时出错: " + err.Error())
+	}
+	if namespace == "" {
+		namespace, err = getCurrentNamespace()
+		if err != nil {
+			return nil, "", errors.New("获取当前命名空间时出错: " + err.Error())
+		}
+	}
+	return clientset, namespace, nil
+}
+
+// RestartPod 删除 Pod，由所属的控制器重新创建
+func RestartPod(namespace, podName string) error {
+	clientset, namespace, err := getK8sClient(namespace)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if metav1.GetControllerOf(pod) == nil {
+		return ErrPodNoController
+	}
+	return clientset.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+}
+
+// PodUsage 通过 metrics-server 读取 Pod 中容器的 CPU 和内存使用情况
+func PodUsage(namespace, podName, container string) (ResourceUsage, error) {
+	clientset, namespace, err := getK8sClient(namespace)
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	ctx := context.Background()
+
+	body, err := clientset.Discovery().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", namespace, "pods", podName).
+		DoRaw(ctx)
+	if err != nil {
+		return ResourceUsage{}, errors.New("读取 Pod 指标时出错，请确认已安装 metrics-server: " + err.Error())
+	}
+	var metrics struct {
+		Containers []struct {
+			Name  string                       `json:"name"`
+			Usage map[string]resource.Quantity `json:"usage"`
+		} `json:"containers"`
+	}
+	if err = json.Unmarshal(body, &metrics); err != nil {
+		return ResourceUsage{}, err
+	}
+
+	var usage ResourceUsage
+	found := false
+	for _, c := range metrics.Containers {
+		if c.Name != container {
+			continue
+		}
+		found = true
+		if cpu, ok := c.Usage["cpu"]; ok {
+			usage.CpuPercent = cpu.AsApproximateFloat64() * 100
+		}
+		if memory, ok := c.Usage["memory"]; ok {
+			usage.MemoryUsage = uint64(memory.Value())
+		}
+	}
+	if !found {
+		return ResourceUsage{}, fmt.Errorf("Pod 指标中找不到容器 %s", container)
+	}
+
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name != container {
+			continue
+		}
+		if limit, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+			usage.MemoryLimit = uint64(limit.Value())
+		}
+	}
+	return usage, nil
+}
+
+// PodLogs 读取 Pod 中容器的日志，tail 为 0 时返回全部日志，follow 为 true 时持续输出直到 ctx 取消
+func PodLogs(ctx context.Context, namespace, podName, container string, tail int, follow bool) (io.ReadCloser, error) {
+	clientset, namespace, err := getK8sClient(namespace)
+	if err != nil {
+		return nil, err
+	}
+	options := &corev1.PodLogOptions{
+		Container: container,
+		Follow:    follow,
+	}
+	if tail > 0 {
+		tailLines := int64(tail)
+		options.TailLines = &tailLines
+	}
+	return clientset.CoreV1().Pods(namespace).GetLogs(podName, options).Stream(ctx)
+}
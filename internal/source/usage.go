@@ -0,0 +1,11 @@
+package source
+
+// ResourceUsage 游戏服务器所在容器的资源使用情况
+type ResourceUsage struct {
+	// CPU 使用率，100 表示占满一个核心
+	CpuPercent float64 `json:"cpu_percent"`
+	// 内存使用量，单位字节
+	MemoryUsage uint64 `json:"memory_usage"`
+	// 内存限制，单位字节，未限制时为 0 或宿主机内存
+	MemoryLimit uint64 `json:"memory_limit"`
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local 将备份保存在本地目录，默认为工作目录下的 backups
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) (*Local, error) {
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(wd, "backups")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir}, nil
+}
+
+func (l *Local) path(name string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(name))
+}
+
+func (l *Local) Put(name string, r io.Reader, size int64) error {
+	path := l.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// 先写入临时文件，完成后再重命名，避免留下不完整的备份
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (l *Local) Get(name string) (io.ReadCloser, error) {
+	file, err := os.Open(l.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	return file, err
+}
+
+func (l *Local) Size(name string) (int64, error) {
+	info, err := os.Stat(l.path(name))
+	if os.IsNotExist(err) {
+		return 0, ErrNotExist
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (l *Local) Delete(name string) error {
+	err := os.Remove(l.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+)
+
+// fakeS3 以路径风格保存对象，只检查请求是否带有签名
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=ak/") || !strings.Contains(auth, "/cn-test/s3/aws4_request") ||
+		r.Header.Get("x-amz-date") == "" || r.Header.Get("x-amz-content-sha256") != "UNSIGNED-PAYLOAD" {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := r.URL.EscapedPath()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestS3(t *testing.T, accessKey string) (*S3, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s3, err := NewS3(config.BackupTarget{
+		Endpoint:  server.URL + "/",
+		Region:    "cn-test",
+		Bucket:    "pst",
+		Path:      "/palworld/",
+		AccessKey: accessKey,
+		SecretKey: "sk",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3, fake
+}
+
+func TestS3(t *testing.T) {
+	s3, fake := newTestS3(t, "ak")
+	name := "servers/pvp/备份 1.zip"
+	content := []byte("backup")
+
+	if err := s3.Put(name, bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["/pst/palworld/servers/pvp/%E5%A4%87%E4%BB%BD%201.zip"]; !ok {
+		t.Fatalf("对象路径不正确: %v", fake.objects)
+	}
+
+	if size, err := s3.Size(name); err != nil || size != int64(len(content)) {
+		t.Fatalf("Size 返回 %d, %v", size, err)
+	}
+
+	r, err := s3.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(data, content) {
+		t.Fatalf("读取的内容为 %q", data)
+	}
+
+	if err := s3.Delete(name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3.Get(name); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("期望 ErrNotExist, 实际为 %v", err)
+	}
+	if _, err := s3.Size(name); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("期望 ErrNotExist, 实际为 %v", err)
+	}
+	// 删除不存在的对象不是错误
+	if err := s3.Delete(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3Error(t *testing.T) {
+	s3, _ := newTestS3(t, "wrong")
+	err := s3.Put("a.zip", strings.NewReader("a"), 1)
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("期望返回 AccessDenied, 实际为 %v", err)
+	}
+}
+
+func TestNewS3(t *testing.T) {
+	if _, err := NewS3(config.BackupTarget{Endpoint: "http://127.0.0.1:9000"}); err == nil {
+		t.Fatal("未设置 bucket 时期望返回错误")
+	}
+	s3, err := NewS3(config.BackupTarget{Endpoint: "http://127.0.0.1:9000", Bucket: "pst"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s3.region != "us-east-1" {
+		t.Fatalf("默认区域为 %q", s3.region)
+	}
+	if s3.client.Timeout == 0 {
+		t.Fatal("HTTP 客户端没有设置超时")
+	}
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP 通过 SFTP 将备份保存到远程目录，每次操作单独建立连接
+type SFTP struct {
+	address string
+	dir     string
+	config  *ssh.ClientConfig
+}
+
+func NewSFTP(cfg config.BackupTarget) (*SFTP, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("sftp 需要设置 address")
+	}
+
+	auths := make([]ssh.AuthMethod, 0, 2)
+	if cfg.PrivateKey != "" {
+		key, err := os.ReadFile(cfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auths = append(auths, ssh.Password(cfg.Password))
+	}
+
+	// 必须校验服务器公钥，否则连接可能被中间人劫持，泄露登录密码和备份文件
+	if cfg.HostKey == "" {
+		return nil, errors.New("sftp 需要设置 host_key, 可以通过 ssh-keyscan 获取服务器公钥")
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("无法解析 host_key: %v", err)
+	}
+
+	dir := cfg.Path
+	if dir == "" {
+		dir = "."
+	}
+	return &SFTP{
+		address: cfg.Address,
+		dir:     dir,
+		config: &ssh.ClientConfig{
+			User:            cfg.Username,
+			Auth:            auths,
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+			Timeout:         10 * time.Second,
+		},
+	}, nil
+}
+
+type sftpConn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+func (c *sftpConn) Close() error {
+	c.sftp.Close()
+	return c.ssh.Close()
+}
+
+func (s *SFTP) connect() (*sftpConn, error) {
+	sshClient, err := ssh.Dial("tcp", s.address, s.config)
+	if err != nil {
+		return nil, err
+	}
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	return &sftpConn{ssh: sshClient, sftp: sftpClient}, nil
+}
+
+func (s *SFTP) Put(name string, r io.Reader, size int64) error {
+	conn, err := s.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	remotePath := path.Join(s.dir, name)
+	if err = conn.sftp.MkdirAll(path.Dir(remotePath)); err != nil {
+		return err
+	}
+	// 先写入临时文件，完成后再重命名，避免留下不完整的备份
+	tmpPath := remotePath + ".tmp"
+	file, err := conn.sftp.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err = file.ReadFrom(r); err != nil {
+		file.Close()
+		conn.sftp.Remove(tmpPath)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		conn.sftp.Remove(tmpPath)
+		return err
+	}
+	conn.sftp.Remove(remotePath)
+	return conn.sftp.Rename(tmpPath, remotePath)
+}
+
+type sftpReader struct {
+	*sftp.File
+	conn *sftpConn
+}
+
+func (r *sftpReader) Close() error {
+	r.File.Close()
+	return r.conn.Close()
+}
+
+func (s *SFTP) Get(name string) (io.ReadCloser, error) {
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	file, err := conn.sftp.Open(path.Join(s.dir, name))
+	if err != nil {
+		conn.Close()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+	return &sftpReader{File: file, conn: conn}, nil
+}
+
+func (s *SFTP) Size(name string) (int64, error) {
+	conn, err := s.connect()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	info, err := conn.sftp.Stat(path.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotExist
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *SFTP) Delete(name string) error {
+	conn, err := s.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.sftp.Remove(path.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNewSFTPHostKey(t *testing.T) {
+	cfg := config.BackupTarget{Type: "sftp", Address: "127.0.0.1:22", Username: "pst", Password: "secret"}
+	if _, err := NewSFTP(cfg); err == nil {
+		t.Fatal("未设置 host_key 时期望返回错误")
+	}
+
+	cfg.HostKey = "ssh-ed25519 invalid"
+	if _, err := NewSFTP(cfg); err == nil {
+		t.Fatal("host_key 无效时期望返回错误")
+	}
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ssh-keyscan 输出的格式，行首为主机名
+	cfg.HostKey = "127.0.0.1 " + string(ssh.MarshalAuthorizedKey(key))
+	if _, err := NewSFTP(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+	"github.com/spf13/viper"
+)
+
+// DefaultTarget 未配置 save.backup_targets 时使用的本地存储名称
+const DefaultTarget = "local"
+
+var ErrNotExist = errors.New("文件不存在")
+
+// httpTimeout 远程存储单次请求的最长时间，包括上传或下载整个备份文件
+const httpTimeout = 30 * time.Minute
+
+// newHTTPClient 返回 s3 和 webdav 使用的 HTTP 客户端，
+// 连接和等待响应头的超时较短，整个请求的超时按大文件传输留出余量
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: httpTimeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: time.Minute,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+// Storage 备份文件的存储后端，name 为相对于存储根目录的路径，以 '/' 分隔
+type Storage interface {
+	Put(name string, r io.Reader, size int64) error
+	Get(name string) (io.ReadCloser, error)
+	Delete(name string) error
+	// Size 返回文件大小，文件不存在时返回 ErrNotExist，无法获取大小时返回 -1
+	Size(name string) (int64, error)
+}
+
+// Target 一个已配置的备份存储目标
+type Target struct {
+	Name     string
+	KeepDays int
+	Storage
+}
+
+// Targets 根据 save.backup_targets 创建所有备份存储目标
+func Targets() ([]Target, error) {
+	configs, err := Configs()
+	if err != nil {
+		return nil, err
+	}
+	return NewTargets(configs, viper.GetInt("save.backup_keep_days"))
+}
+
+// Configs 返回 save.backup_targets 配置，未配置时使用本地存储
+func Configs() ([]config.BackupTarget, error) {
+	var configs []config.BackupTarget
+	if err := viper.UnmarshalKey("save.backup_targets", &configs); err != nil {
+		return nil, fmt.Errorf("无法解析 save.backup_targets: %s", err)
+	}
+	if len(configs) == 0 {
+		configs = []config.BackupTarget{{Name: DefaultTarget, Type: "local"}}
+	}
+	return configs, nil
+}
+
+// NewTargets 根据配置创建备份存储目标，未单独设置 keep_days 的目标保留 keepDays 天
+func NewTargets(configs []config.BackupTarget, keepDays int) ([]Target, error) {
+	targets := make([]Target, 0, len(configs))
+	names := make(map[string]bool, len(configs))
+	for _, cfg := range configs {
+		if cfg.Name == "" {
+			cfg.Name = cfg.Type
+		}
+		if names[cfg.Name] {
+			return nil, fmt.Errorf("备份存储目标名称重复: %s", cfg.Name)
+		}
+		names[cfg.Name] = true
+
+		s, err := New(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("备份存储目标 %s: %s", cfg.Name, err)
+		}
+		// 未单独设置保留天数时使用服务器的 backup_keep_days
+		days := cfg.KeepDays
+		if days == 0 {
+			days = keepDays
+		}
+		if days == 0 {
+			days = 7
+		}
+		targets = append(targets, Target{Name: cfg.Name, KeepDays: days, Storage: s})
+	}
+	return targets, nil
+}
+
+// New 根据配置创建存储后端
+func New(cfg config.BackupTarget) (Storage, error) {
+	switch cfg.Type {
+	case "local", "":
+		return NewLocal(cfg.Path)
+	case "s3":
+		return NewS3(cfg)
+	case "sftp":
+		return NewSFTP(cfg)
+	case "webdav":
+		return NewWebDAV(cfg)
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", cfg.Type)
+	}
+}
+
+// prefixed 将所有文件保存在 prefix 目录下
+type prefixed struct {
+	Storage
+	prefix string
+}
+
+func (p prefixed) Put(name string, r io.Reader, size int64) error {
+	return p.Storage.Put(p.prefix+name, r, size)
+}
+
+func (p prefixed) Get(name string) (io.ReadCloser, error) {
+	return p.Storage.Get(p.prefix + name)
+}
+
+func (p prefixed) Delete(name string) error {
+	return p.Storage.Delete(p.prefix + name)
+}
+
+func (p prefixed) Size(name string) (int64, error) {
+	return p.Storage.Size(p.prefix + name)
+}
+
+// WithPrefix 返回将所有文件保存到 prefix 目录下的存储目标，用于隔离不同服务器的备份
+func WithPrefix(target Target, prefix string) Target {
+	target.Storage = prefixed{Storage: target.Storage, prefix: prefix}
+	return target
+}
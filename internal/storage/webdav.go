@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+)
+
+// WebDAV 通过 WebDAV 将备份保存到远程目录
+type WebDAV struct {
+	endpoint string
+	prefix   string
+	username string
+	password string
+	client   *http.Client
+}
+
+func NewWebDAV(cfg config.BackupTarget) (*WebDAV, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("webdav 需要设置 endpoint")
+	}
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+	return &WebDAV{
+		endpoint: endpoint,
+		prefix:   strings.Trim(cfg.Path, "/"),
+		username: cfg.Username,
+		password: cfg.Password,
+		client:   newHTTPClient(),
+	}, nil
+}
+
+// segments 返回 name 加上路径前缀后的各级路径
+func (w *WebDAV) segments(name string) []string {
+	if w.prefix != "" {
+		name = w.prefix + "/" + name
+	}
+	return strings.Split(name, "/")
+}
+
+func (w *WebDAV) url(name string) string {
+	u := w.endpoint
+	for _, part := range w.segments(name) {
+		u += "/" + url.PathEscape(part)
+	}
+	return u
+}
+
+func (w *WebDAV) request(method, u string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	return w.client.Do(req)
+}
+
+// mkcol 逐级创建 name 所在的目录，已存在的目录会返回 405，忽略即可
+func (w *WebDAV) mkcol(name string) error {
+	dirs := w.segments(name)
+	u := w.endpoint
+	for _, dir := range dirs[:len(dirs)-1] {
+		u += "/" + url.PathEscape(dir)
+		resp, err := w.request("MKCOL", u+"/", nil, 0)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("webdav 创建目录失败: %s", resp.Status)
+		}
+	}
+	return nil
+}
+
+func (w *WebDAV) Put(name string, r io.Reader, size int64) error {
+	if err := w.mkcol(name); err != nil {
+		return err
+	}
+	resp, err := w.request(http.MethodPut, w.url(name), r, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webdav 上传失败: %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *WebDAV) Get(name string) (io.ReadCloser, error) {
+	resp, err := w.request(http.MethodGet, w.url(name), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotExist
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("webdav 下载失败: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Size 使用 HEAD 请求获取文件大小，服务器未返回 Content-Length 时为 -1
+func (w *WebDAV) Size(name string) (int64, error) {
+	resp, err := w.request(http.MethodHead, w.url(name), nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, ErrNotExist
+	}
+	if resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("webdav 获取文件信息失败: %s", resp.Status)
+	}
+	return resp.ContentLength, nil
+}
+
+func (w *WebDAV) Delete(name string) error {
+	resp, err := w.request(http.MethodDelete, w.url(name), nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("webdav 删除失败: %s", resp.Status)
+	}
+	return nil
+}
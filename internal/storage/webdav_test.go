@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+)
+
+// fakeWebDAV 与常见 WebDAV 服务一致: 父目录不存在时 PUT 返回 409，目录已存在时 MKCOL 返回 405
+type fakeWebDAV struct {
+	mu    sync.Mutex
+	dirs  map[string]bool
+	files map[string][]byte
+}
+
+func (f *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "pst" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	switch r.Method {
+	case "MKCOL":
+		if f.dirs[p] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !f.dirs[path.Dir(p)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.dirs[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		if !f.dirs[path.Dir(p)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.files[p] = data
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		data, ok := f.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodHead:
+		data, ok := f.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	case http.MethodDelete:
+		if _, ok := f.files[p]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.files, p)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestWebDAV(t *testing.T, password string) (*WebDAV, *fakeWebDAV) {
+	t.Helper()
+	fake := &fakeWebDAV{dirs: map[string]bool{"/": true, "/dav": true}, files: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	dav, err := NewWebDAV(config.BackupTarget{
+		Endpoint: server.URL + "/dav/",
+		Path:     "palworld",
+		Username: "pst",
+		Password: password,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dav, fake
+}
+
+func TestWebDAV(t *testing.T) {
+	dav, fake := newTestWebDAV(t, "secret")
+	name := "servers/pvp/备份 1.zip"
+	content := []byte("backup")
+
+	// 第二次上传时目录已存在
+	for i := 0; i < 2; i++ {
+		if err := dav.Put(name, bytes.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := fake.files["/dav/palworld/servers/pvp/备份 1.zip"]; !ok {
+		t.Fatalf("文件路径不正确: %v", fake.files)
+	}
+
+	if size, err := dav.Size(name); err != nil || size != int64(len(content)) {
+		t.Fatalf("Size 返回 %d, %v", size, err)
+	}
+
+	r, err := dav.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(data, content) {
+		t.Fatalf("读取的内容为 %q", data)
+	}
+
+	if err := dav.Delete(name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dav.Get(name); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("期望 ErrNotExist, 实际为 %v", err)
+	}
+	if _, err := dav.Size(name); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("期望 ErrNotExist, 实际为 %v", err)
+	}
+	// 删除不存在的文件不是错误
+	if err := dav.Delete(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebDAVError(t *testing.T) {
+	dav, _ := newTestWebDAV(t, "wrong")
+	if err := dav.Put("a.zip", strings.NewReader("a"), 1); err == nil {
+		t.Fatal("认证失败时期望返回错误")
+	}
+	if _, err := dav.Get("a.zip"); err == nil || errors.Is(err, ErrNotExist) {
+		t.Fatalf("认证失败时期望返回错误, 实际为 %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package supervisor
+
+import (
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// wait 等待服务器进程退出，在回收进程前结束进程组中仍在运行的游戏进程。
+// 未回收的进程仍占用其 PID，此时向进程组发送信号不会影响其他进程
+func (g *processGroup) wait(cmd *exec.Cmd) error {
+	var info unix.Siginfo
+	for {
+		err := unix.Waitid(unix.P_PID, g.pid, &info, unix.WEXITED|unix.WNOWAIT, nil)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	g.mu.Lock()
+	syscall.Kill(-g.pid, syscall.SIGKILL)
+	g.exited = true
+	g.mu.Unlock()
+	return cmd.Wait()
+}
+
+// signal 向进程组发送信号，进程组的首进程被回收后不再发送
+func (g *processGroup) signal(sig syscall.Signal) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.exited {
+		return ErrStopped
+	}
+	return syscall.Kill(-g.pid, sig)
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !windows
+
+package supervisor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// wait 等待服务器进程退出。该系统无法在回收进程前等待其退出，
+// 回收后进程组的 PID 可能被复用，因此不再结束进程组中剩余的进程
+func (g *processGroup) wait(cmd *exec.Cmd) error {
+	return cmd.Wait()
+}
+
+// signal 只向服务器进程发送信号，os.Process 不会向已回收的进程发送信号
+func (g *processGroup) signal(sig syscall.Signal) error {
+	return g.process.Signal(sig)
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package supervisor
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// processGroup 服务器进程所在的进程组，PalServer.sh 启动的游戏进程也在该进程组中
+type processGroup struct {
+	mu      sync.Mutex
+	pid     int
+	process *os.Process
+	// 进程组的首进程是否已经退出，退出后不再向进程组发送信号，避免其 PID 被复用后误杀其他进程
+	exited bool
+}
+
+// setProcessGroup 让服务器进程使用单独的进程组
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func newProcessGroup(cmd *exec.Cmd) (*processGroup, error) {
+	return &processGroup{pid: cmd.Process.Pid, process: cmd.Process}, nil
+}
+
+// interrupt 向服务器的整个进程组发送中断信号
+func (g *processGroup) interrupt() error {
+	return g.signal(syscall.SIGINT)
+}
+
+// kill 强制结束服务器的整个进程组
+func (g *processGroup) kill() error {
+	return g.signal(syscall.SIGKILL)
+}
@@ -0,0 +1,80 @@
+//go:build windows
+
+package supervisor
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// processGroup 服务器进程所在的作业对象，PalServer.exe 启动的游戏进程也会加入该作业。
+// 通过作业句柄结束进程，不会误杀进程退出后复用了同一个 PID 的其他进程
+type processGroup struct {
+	mu  sync.Mutex
+	job windows.Handle
+}
+
+// setProcessGroup 让服务器进程使用单独的进程组
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// newProcessGroup 将刚启动的服务器进程加入设置了 KILL_ON_JOB_CLOSE 的作业对象，
+// pst 退出时系统也会结束作业中剩余的进程
+func newProcessGroup(cmd *exec.Cmd) (*processGroup, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	_, err = windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	// 进程尚未被等待，exec.Cmd 持有进程句柄，此时 PID 不会被复用
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	defer windows.CloseHandle(process)
+	if err = windows.AssignProcessToJobObject(job, process); err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	return &processGroup{job: job}, nil
+}
+
+// interrupt Windows 不支持发送中断信号
+func (g *processGroup) interrupt() error {
+	return errors.New("不支持发送中断信号")
+}
+
+// kill 强制结束作业中的所有进程
+func (g *processGroup) kill() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.job == 0 {
+		return ErrStopped
+	}
+	return windows.TerminateJobObject(g.job, 1)
+}
+
+// wait 等待服务器进程退出，之后结束作业中仍在运行的游戏进程并关闭作业
+func (g *processGroup) wait(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	windows.TerminateJobObject(g.job, 1)
+	windows.CloseHandle(g.job)
+	g.job = 0
+	return err
+}
@@ -0,0 +1,393 @@
+package supervisor
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
+)
+
+const (
+	StateStopped  = "stopped"
+	StateRunning  = "running"
+	StateStopping = "stopping"
+	// 进程退出后等待重启
+	StateBackoff = "backoff"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+	// 运行超过该时间后退出，重启等待时间重新从 minBackoff 开始
+	stableTime = 5 * time.Minute
+)
+
+var (
+	ErrDisabled = errors.New("未启用进程守护 supervisor.enabled")
+	ErrRunning  = errors.New("服务器进程已在运行")
+	ErrStopped  = errors.New("服务器进程未运行")
+)
+
+type Status struct {
+	Enabled      bool       `json:"enabled"`
+	State        string     `json:"state"`
+	Pid          int        `json:"pid"`
+	StartTime    *time.Time `json:"start_time"`
+	Restarts     int        `json:"restarts"`
+	LastExit     string     `json:"last_exit"`
+	LastExitTime *time.Time `json:"last_exit_time"`
+}
+
+// process 一个服务器的守护状态
+type process struct {
+	sync.Mutex
+	id string
+	// 是否应当保持进程运行
+	wanted bool
+	// 守护循环是否在运行
+	looping bool
+	state   string
+	cmd     *exec.Cmd
+	// 服务器进程及其子进程所在的进程组
+	group *processGroup
+	// 进程退出时关闭
+	done         chan struct{}
+	wake         chan struct{}
+	startTime    time.Time
+	restarts     int
+	lastExit     string
+	lastExitTime time.Time
+}
+
+// 每个服务器的守护状态，按服务器 ID 保存
+var (
+	processesMu sync.Mutex
+	processes   = make(map[string]*process)
+)
+
+func getProcess(id string) *process {
+	processesMu.Lock()
+	defer processesMu.Unlock()
+	p, ok := processes[id]
+	if !ok {
+		p = &process{id: id}
+		processes[id] = p
+	}
+	return p
+}
+
+// emit 发送服务器的事件通知，额外服务器的消息带有服务器名称
+func emit(srv *tool.Server, eventType, message string, data map[string]interface{}) {
+	if !srv.IsDefault() {
+		message = "[" + srv.Name + "] " + message
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		data["server_id"] = srv.Id
+	}
+	webhook.Emit(eventType, message, data)
+}
+
+func Enabled(srv *tool.Server) bool {
+	return srv.Supervisor.Enabled
+}
+
+// StartAll 启动所有启用了进程守护的服务器
+func StartAll() {
+	for _, srv := range tool.Servers() {
+		if !Enabled(srv) {
+			continue
+		}
+		if err := Start(srv); err != nil {
+			logger.Errorf("无法启动服务器 %s 的进程: %v\n", srv.Id, err)
+		}
+	}
+}
+
+// StopAll 关闭所有由 pst 启动的服务器进程
+func StopAll() {
+	processesMu.Lock()
+	ids := make([]string, 0, len(processes))
+	for id := range processes {
+		ids = append(ids, id)
+	}
+	processesMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		srv, err := tool.GetServer(id)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Stop(srv); err != nil && err != ErrStopped {
+				logger.Errorf("无法关闭服务器 %s 的进程: %v\n", srv.Id, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Start 启动服务器进程，进程意外退出后会自动重启
+func Start(srv *tool.Server) error {
+	if !Enabled(srv) {
+		return ErrDisabled
+	}
+	if srv.Supervisor.Path == "" {
+		return errors.New("未设置服务器程序路径 supervisor.path")
+	}
+	p := getProcess(srv.Id)
+	p.Lock()
+	defer p.Unlock()
+	if p.wanted {
+		return ErrRunning
+	}
+	p.wanted = true
+	if !p.looping {
+		p.looping = true
+		p.wake = make(chan struct{}, 1)
+		go p.loop()
+	}
+	return nil
+}
+
+// Stop 关闭服务器进程，先通过 REST API 正常关闭，超时后强制结束。
+// 配置修改为禁用进程守护后，仍然可以关闭已经启动的进程
+func Stop(srv *tool.Server) error {
+	p := getProcess(srv.Id)
+	p.Lock()
+	if !p.wanted && p.cmd == nil {
+		p.Unlock()
+		return ErrStopped
+	}
+	p.wanted = false
+	cmd, group, done := p.cmd, p.group, p.done
+	if cmd != nil {
+		p.state = StateStopping
+	}
+	// 结束重启前的等待
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	p.Unlock()
+	if cmd == nil {
+		return nil
+	}
+
+	logger.Infof("正在关闭服务器 %s 的进程...\n", srv.Id)
+	if err := srv.Shutdown(1, "Server is stopping"); err != nil {
+		logger.Warnf("无法通过 REST API 关闭服务器: %v\n", err)
+		// Windows 不支持发送中断信号，直接结束进程
+		if err = group.interrupt(); err != nil {
+			group.kill()
+		}
+	}
+
+	timeout := time.Duration(srv.Supervisor.StopTimeout) * time.Second
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Warnf("服务器 %s 的进程 %v 内未退出，强制结束\n", srv.Id, timeout)
+		group.kill()
+		<-done
+	}
+	return nil
+}
+
+// Restart 重启服务器进程
+func Restart(srv *tool.Server) error {
+	// 禁用进程守护时不关闭进程，避免关闭后无法再启动
+	if !Enabled(srv) {
+		return ErrDisabled
+	}
+	if err := Stop(srv); err != nil && err != ErrStopped {
+		return err
+	}
+	return Start(srv)
+}
+
+func GetStatus(srv *tool.Server) Status {
+	p := getProcess(srv.Id)
+	p.Lock()
+	defer p.Unlock()
+	status := Status{
+		Enabled:  Enabled(srv),
+		State:    p.state,
+		Restarts: p.restarts,
+		LastExit: p.lastExit,
+	}
+	if status.State == "" {
+		status.State = StateStopped
+	}
+	if p.cmd != nil {
+		status.Pid = p.cmd.Process.Pid
+		startTime := p.startTime
+		status.StartTime = &startTime
+	}
+	if !p.lastExitTime.IsZero() {
+		lastExitTime := p.lastExitTime
+		status.LastExitTime = &lastExitTime
+	}
+	return status
+}
+
+// launch 按服务器当前的配置启动一次服务器进程，需要持有锁
+func (p *process) launch(srv *tool.Server) error {
+	path := srv.Supervisor.Path
+	cmd := exec.Command(path, srv.Supervisor.Args...)
+	cmd.Dir = srv.Supervisor.WorkDir
+	if cmd.Dir == "" {
+		cmd.Dir = filepath.Dir(path)
+	}
+	out := &lineWriter{prefix: "[PalServer] "}
+	if !srv.IsDefault() {
+		out.prefix = "[PalServer " + srv.Id + "] "
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// 服务器派生的子进程可能继续占用输出，进程退出后不再等待
+	cmd.WaitDelay = time.Second
+	// PalServer.sh 在子进程中运行游戏，结束时需要结束整个进程组
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	group, err := newProcessGroup(cmd)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	p.cmd = cmd
+	p.group = group
+	p.done = make(chan struct{})
+	p.state = StateRunning
+	p.startTime = time.Now()
+	logger.Infof("服务器 %s 的进程已启动, pid: %d\n", srv.Id, cmd.Process.Pid)
+	return nil
+}
+
+func (p *process) loop() {
+	backoff := minBackoff
+	for {
+		p.Lock()
+		// 每次启动时重新读取配置，服务器已从配置中移除或禁用进程守护时不再启动
+		srv, err := tool.GetServer(p.id)
+		if err == nil && !Enabled(srv) {
+			err = ErrDisabled
+		}
+		if err != nil {
+			p.wanted = false
+		}
+		if !p.wanted {
+			p.state = StateStopped
+			p.looping = false
+			p.Unlock()
+			return
+		}
+		err = p.launch(srv)
+		cmd, group, done, startTime := p.cmd, p.group, p.done, p.startTime
+		p.Unlock()
+
+		var ran time.Duration
+		if err == nil {
+			// 启动脚本退出后游戏进程可能仍在运行，wait 会结束整个进程组，避免重启后同时运行两个服务器
+			err = group.wait(cmd)
+			cmd.Stdout.(*lineWriter).Flush()
+			ran = time.Since(startTime)
+		}
+
+		exit := "exit status 0"
+		if err != nil {
+			exit = err.Error()
+		}
+		p.Lock()
+		p.cmd = nil
+		p.group = nil
+		p.lastExit = exit
+		p.lastExitTime = time.Now()
+		if done != nil {
+			close(done)
+		}
+		if !p.wanted {
+			logger.Infof("服务器 %s 的进程已退出: %s\n", srv.Id, exit)
+			p.state = StateStopped
+			p.looping = false
+			p.Unlock()
+			return
+		}
+		if ran >= stableTime {
+			backoff = minBackoff
+		}
+		p.state = StateBackoff
+		p.restarts++
+		wake := p.wake
+		p.Unlock()
+
+		logger.Warnf("服务器 %s 的进程已退出: %s, %v 后重启\n", srv.Id, exit, backoff)
+		// 正常退出(例如通过 REST API 关闭)时只重启，不视为崩溃
+		if err != nil {
+			emit(srv, webhook.EventServerCrash, "Server process exited: "+exit, map[string]interface{}{
+				"error":   exit,
+				"backoff": backoff.String(),
+			})
+		}
+		select {
+		case <-time.After(backoff):
+		case <-wake:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// lineWriter 将服务器的输出按行写入日志
+type lineWriter struct {
+	mu     sync.Mutex
+	buf    []byte
+	prefix string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush 输出最后不以换行结尾的内容
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.log(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) log(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return
+	}
+	logger.Infof("%s%s\n", w.prefix, line)
+}
@@ -425,3 +425,48 @@ func UnTarGzDir(tarStream io.Reader, destDir string) error {
 
 	return nil
 }
+
+// TarGzDir 将 srcDir 中的文件以相对路径打包为 tar.gz 写入 w
+func TarGzDir(srcDir string, w io.Writer) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+
+		// 创建tar头信息，路径分隔符统一为'/'
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = strings.ReplaceAll(relPath, string(os.PathSeparator), "/")
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		// 写入文件内容
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
@@ -0,0 +1,123 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/service"
+	"github.com/robfig/cron/v3"
+	"go.etcd.io/bbolt"
+)
+
+// 定时公告的最小间隔，单位秒
+const minAnnouncementInterval = 60
+
+// announcementsTag 标记所有定时公告的任务，服务器配置变化后重新创建
+const announcementsTag = "announcement"
+
+func announcementTag(serverId, id string) string {
+	return "announcement:" + serverId + ":" + id
+}
+
+// ValidateAnnouncement 检查定时公告的执行时间和内容是否有效
+func ValidateAnnouncement(announcement database.Announcement) error {
+	if strings.TrimSpace(announcement.Message) == "" {
+		return errors.New("公告内容不能为空")
+	}
+	if (announcement.Cron == "") == (announcement.Interval == 0) {
+		return errors.New("cron 表达式与间隔时间必须且只能设置一个")
+	}
+	if announcement.Cron != "" {
+		schedule, err := cron.ParseStandard(announcement.Cron)
+		if err != nil {
+			return fmt.Errorf("无效的 cron 表达式: %s", err)
+		}
+		// 标准 cron 表达式的最小间隔为一分钟，@every 描述符可以设置更短的间隔
+		if every, ok := schedule.(cron.ConstantDelaySchedule); ok && every.Delay < minAnnouncementInterval*time.Second {
+			return fmt.Errorf("间隔时间不能小于 %d 秒", minAnnouncementInterval)
+		}
+	} else if announcement.Interval < minAnnouncementInterval {
+		return fmt.Errorf("间隔时间不能小于 %d 秒", minAnnouncementInterval)
+	}
+	return nil
+}
+
+// ScheduleAnnouncement 注册或更新服务器的定时公告，未启用的公告只会移除已有的任务
+func ScheduleAnnouncement(srv *tool.Server, db *bbolt.DB, announcement database.Announcement) error {
+	s := getScheduler()
+	tag := announcementTag(srv.Id, announcement.Id)
+	s.RemoveByTags(tag)
+	if !announcement.Enabled {
+		return nil
+	}
+
+	var definition gocron.JobDefinition
+	if announcement.Cron != "" {
+		definition = gocron.CronJob(announcement.Cron, false)
+	} else {
+		definition = gocron.DurationJob(time.Duration(announcement.Interval) * time.Second)
+	}
+	_, err := s.NewJob(
+		definition,
+		gocron.NewTask(AnnouncementTask, srv, db, announcement.Id),
+		gocron.WithName("announcement:"+srv.Id),
+		gocron.WithTags(announcementsTag, tag),
+	)
+	return err
+}
+
+// UnscheduleAnnouncement 移除服务器定时公告的任务
+func UnscheduleAnnouncement(srv *tool.Server, id string) {
+	getScheduler().RemoveByTags(announcementTag(srv.Id, id))
+}
+
+// scheduleAnnouncements 注册所有服务器已保存的定时公告
+func scheduleAnnouncements() {
+	for _, srv := range tool.Servers() {
+		db := srv.DB()
+		announcements, err := service.ListAnnouncements(db)
+		if err != nil {
+			logger.Errorf("%v\n", err)
+			continue
+		}
+		for _, announcement := range announcements {
+			if err := ScheduleAnnouncement(srv, db, announcement); err != nil {
+				logger.Errorf("无法注册服务器 %s 的定时公告 %s: %v\n", srv.Id, announcement.Name, err)
+			}
+		}
+	}
+}
+
+func AnnouncementTask(srv *tool.Server, db *bbolt.DB, id string) {
+	// 每次执行时重新读取，使用最新的公告内容
+	announcement, err := service.GetAnnouncement(db, id)
+	if err != nil || !announcement.Enabled {
+		return
+	}
+
+	onlinePlayers, err := srv.ShowPlayers()
+	if err != nil {
+		logger.Warnf("服务器 %s 的定时公告 %s 发送失败, %s \n", srv.Id, announcement.Name, err)
+		return
+	}
+
+	now := time.Now()
+	message := strings.ReplaceAll(announcement.Message, "{time}", now.Format("15:04"))
+	message = strings.ReplaceAll(message, "{date}", now.Format("2006-01-02"))
+	if strings.Contains(message, "{server_name}") {
+		if info, err := srv.Info(); err == nil {
+			message = strings.ReplaceAll(message, "{server_name}", info["name"])
+		}
+	}
+	broadcastVariableMessage(srv, message, "", len(onlinePlayers))
+
+	if err = service.SetAnnouncementLastRun(db, id, now); err != nil && err != service.ErrNoRecord {
+		logger.Errorf("%v\n", err)
+	}
+}
@@ -0,0 +1,182 @@
+package task
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
+	"github.com/qycnet/palworld-server-tool-main/service"
+)
+
+// 封禁到期任务和接口都会解除全局封禁，避免同时解除同一个封禁
+var globalBanMu sync.Mutex
+
+// ApplyGlobalBan 在所有服务器上封禁玩家，返回封禁失败的服务器 ID，
+// 失败的服务器会在玩家上线时由玩家同步任务重新封禁
+func ApplyGlobalBan(ban database.Ban) []string {
+	return eachServer(func(srv *tool.Server) error {
+		return srv.BanPlayer(fmt.Sprintf("steam_%s", ban.SteamId))
+	})
+}
+
+// RemoveGlobalBan 在所有服务器上解除全局封禁，返回解除失败的服务器 ID。
+// 全部成功时删除封禁记录，否则将失败的服务器记录在 PendingUnban 中，
+// 封禁不再生效，由封禁到期任务重试
+func RemoveGlobalBan(ban database.Ban) ([]string, error) {
+	globalBanMu.Lock()
+	defer globalBanMu.Unlock()
+	return removeGlobalBan(ban)
+}
+
+func removeGlobalBan(ban database.Ban) ([]string, error) {
+	failed := eachServer(func(srv *tool.Server) error {
+		return liftGlobalBan(srv, ban)
+	})
+	db := database.GetDB()
+	if len(failed) == 0 {
+		return failed, service.DeleteGlobalBan(db, ban.SteamId)
+	}
+	ban.PendingUnban = failed
+	return failed, service.PutGlobalBan(db, ban)
+}
+
+// GlobalBanActive 返回 SteamID 是否仍有生效的全局封禁，解除服务器自身的封禁时保留游戏内的封禁
+func GlobalBanActive(steamId string) bool {
+	ban, err := service.GetGlobalBan(database.GetDB(), steamId)
+	if err != nil {
+		return false
+	}
+	return len(ban.PendingUnban) == 0 && (ban.ExpiresAt.IsZero() || time.Now().Before(ban.ExpiresAt))
+}
+
+// liftGlobalBan 在一个服务器上解除全局封禁，服务器自身也封禁了该玩家时保留封禁
+func liftGlobalBan(srv *tool.Server, ban database.Ban) error {
+	bans, err := service.ListBans(srv.DB())
+	if err != nil {
+		return err
+	}
+	for _, local := range bans {
+		if local.SteamId == ban.SteamId {
+			return nil
+		}
+	}
+	return srv.UnBanPlayer(fmt.Sprintf("steam_%s", ban.SteamId))
+}
+
+// retryUnban 在解除失败的服务器上重试解除已解除的全局封禁，已删除的服务器不再重试
+func retryUnban(ban database.Ban) {
+	pending := make([]string, 0, len(ban.PendingUnban))
+	for _, id := range ban.PendingUnban {
+		srv, err := tool.GetServer(id)
+		if err != nil {
+			continue
+		}
+		if err = liftGlobalBan(srv, ban); err != nil {
+			logger.Warnf("解除 %s 的全局封禁失败, %s \n", ban.Nickname, err)
+			pending = append(pending, id)
+		}
+	}
+	if len(pending) == len(ban.PendingUnban) {
+		return
+	}
+
+	db := database.GetDB()
+	var err error
+	if len(pending) == 0 {
+		err = service.DeleteGlobalBan(db, ban.SteamId)
+	} else {
+		ban.PendingUnban = pending
+		err = service.PutGlobalBan(db, ban)
+	}
+	if err != nil {
+		logger.Errorf("%v\n", err)
+	}
+}
+
+func eachServer(fn func(srv *tool.Server) error) []string {
+	failed := make([]string, 0)
+	for _, srv := range tool.Servers() {
+		if err := fn(srv); err != nil {
+			logger.Warnf("服务器 %s 操作失败, %s \n", srv.Id, err)
+			failed = append(failed, srv.Id)
+		}
+	}
+	return failed
+}
+
+// expireGlobalBans 解除到期的全局封禁，并重试之前解除失败的服务器
+func expireGlobalBans() {
+	globalBanMu.Lock()
+	defer globalBanMu.Unlock()
+
+	bans, err := service.ListGlobalBans(database.GetDB())
+	if err != nil {
+		logger.Errorf("%v\n", err)
+		return
+	}
+
+	now := time.Now()
+	for _, ban := range bans {
+		if len(ban.PendingUnban) > 0 {
+			retryUnban(ban)
+			continue
+		}
+		if ban.ExpiresAt.IsZero() || !now.After(ban.ExpiresAt) {
+			continue
+		}
+		failed, err := removeGlobalBan(ban)
+		if err != nil {
+			logger.Errorf("%v\n", err)
+			continue
+		}
+		if len(failed) > 0 {
+			logger.Warnf("解除 %s 的全局封禁失败, 将在之后重试: %v \n", ban.Nickname, failed)
+		}
+		logger.Infof("%s 的全局封禁已到期, 已解除封禁\n", ban.Nickname)
+		webhook.Emit(webhook.EventPlayerUnban, ban.Nickname+"'s global ban has expired", globalBanData(ban))
+	}
+}
+
+// globalBanSync 重新封禁仍然在线的被全局封禁的玩家，已到期或已解除的封禁由 expireGlobalBans 处理
+func globalBanSync(srv *tool.Server, players []database.OnlinePlayer) {
+	globalBanMu.Lock()
+	defer globalBanMu.Unlock()
+
+	bans, err := service.ListGlobalBans(database.GetDB())
+	if err != nil {
+		logger.Errorf("%v\n", err)
+		return
+	}
+
+	online := make(map[string]bool, len(players))
+	for _, player := range players {
+		if player.SteamId != "" {
+			online[player.SteamId] = true
+		}
+	}
+
+	now := time.Now()
+	for _, ban := range bans {
+		if len(ban.PendingUnban) > 0 || (!ban.ExpiresAt.IsZero() && now.After(ban.ExpiresAt)) {
+			continue
+		}
+		if online[ban.SteamId] {
+			if err := srv.BanPlayer(fmt.Sprintf("steam_%s", ban.SteamId)); err != nil {
+				logger.Warnf("重新封禁 %s 失败, %s \n", ban.Nickname, err)
+				continue
+			}
+			logger.Warnf("重新封禁 %s 成功 \n", ban.Nickname)
+			Emit(srv, webhook.EventPlayerBan, ban.Nickname+" was banned again by the global ban list", globalBanData(ban))
+		}
+	}
+}
+
+func globalBanData(ban database.Ban) map[string]interface{} {
+	data := banData(ban)
+	data["global"] = true
+	return data
+}
@@ -0,0 +1,126 @@
+package task
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/event"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
+	"github.com/qycnet/palworld-server-tool-main/service"
+	"github.com/spf13/viper"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// 原始采样保留的时间，之后按小时汇总
+	metricsRawRetention = 24 * time.Hour
+	// 连续多少次采样低于阈值才视为帧率过低，避免偶尔的卡顿触发通知
+	lowFpsSamples = 3
+)
+
+// fpsState 连续低帧率的采样次数，以及是否已经发送过帧率过低的通知
+type fpsState struct {
+	count int
+	low   bool
+}
+
+// 每个服务器的帧率状态，同一服务器的采集任务不会并发执行
+var (
+	fpsStatesMu sync.Mutex
+	fpsStates   = make(map[string]*fpsState)
+)
+
+func getFpsState(id string) *fpsState {
+	fpsStatesMu.Lock()
+	defer fpsStatesMu.Unlock()
+	state, ok := fpsStates[id]
+	if !ok {
+		state = &fpsState{}
+		fpsStates[id] = state
+	}
+	return state
+}
+
+func MetricsTask(db *bbolt.DB) error {
+	return ServerMetricsTask(tool.DefaultServer(), db)
+}
+
+// ServerMetricsTask 采集指定服务器的性能指标
+func ServerMetricsTask(srv *tool.Server, db *bbolt.DB) error {
+	now := time.Now()
+	point := database.MetricsPoint{
+		Time:    now,
+		Samples: 1,
+	}
+
+	metrics, err := srv.Metrics()
+	metricsErr := err
+	if err != nil {
+		logger.Warnf("获取服务器 %s 指标失败: %v\n", srv.Id, err)
+		if !serverUnreachable(srv.Id).Swap(true) {
+			Emit(srv, webhook.EventServerUnreachable, "Server is unreachable: "+err.Error(), map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	} else {
+		if serverUnreachable(srv.Id).Swap(false) {
+			Emit(srv, webhook.EventServerRecovered, "Server is reachable again", nil)
+		}
+		point.OnlineSamples = 1
+		point.ServerFps = float64(metrics["server_fps"].(int))
+		point.MinServerFps = metrics["server_fps"].(int)
+		point.ServerFrameTime = metrics["server_frame_time"].(float64)
+		point.CurrentPlayerNum = metrics["current_player_num"].(int)
+		point.Uptime = metrics["uptime"].(int)
+		checkFps(srv, point.MinServerFps)
+		event.Publish(event.Event{Type: event.EventMetrics, Server: eventServer(srv), Data: metrics})
+	}
+
+	if err = service.AddMetricsPoint(db, point); err != nil {
+		logger.Errorf("%v\n", err)
+	}
+	if err = service.DownsampleMetrics(db, now.Add(-metricsRawRetention)); err != nil {
+		logger.Errorf("%v\n", err)
+	}
+	// 保留天数设置为0时永久保留按小时汇总的指标
+	if keepDays := viper.GetInt("task.metrics_keep_days"); keepDays > 0 {
+		if err = service.CleanMetrics(db, now.AddDate(0, 0, -keepDays)); err != nil {
+			logger.Errorf("%v\n", err)
+		}
+	}
+	// 服务器无法访问时任务记为失败
+	return metricsErr
+}
+
+// checkFps 帧率连续低于 task.low_fps_threshold 时发送通知，恢复后再次通知
+func checkFps(srv *tool.Server, fps int) {
+	threshold := viper.GetInt("task.low_fps_threshold")
+	if threshold <= 0 {
+		return
+	}
+	state := getFpsState(srv.Id)
+	if fps >= threshold {
+		state.count = 0
+		if state.low {
+			state.low = false
+			logger.Infof("服务器 %s 帧率已恢复: %d\n", srv.Id, fps)
+			Emit(srv, webhook.EventServerFpsRecovered, fmt.Sprintf("Server FPS recovered to %d", fps), map[string]interface{}{
+				"server_fps": fps,
+			})
+		}
+		return
+	}
+	state.count++
+	if state.count >= lowFpsSamples && !state.low {
+		state.low = true
+		logger.Warnf("服务器 %s 帧率过低: %d\n", srv.Id, fps)
+		Emit(srv, webhook.EventServerFpsLow, fmt.Sprintf("Server FPS dropped to %d", fps), map[string]interface{}{
+			"server_fps": fps,
+			"threshold":  threshold,
+		})
+	}
+}
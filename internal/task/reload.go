@@ -0,0 +1,56 @@
+package task
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/spf13/viper"
+	"go.etcd.io/bbolt"
+)
+
+// intervalTag 标记按配置的间隔执行的任务
+const intervalTag = "interval"
+
+var (
+	rescheduleMu sync.Mutex
+	// 创建任务时使用的配置，配置重新加载后与其比较
+	scheduleSignature string
+)
+
+// intervalSignature 返回影响间隔任务的配置，额外服务器的任务保存了创建时的服务器配置，因此也包含在内
+func intervalSignature() string {
+	signature, _ := json.Marshal(map[string]interface{}{
+		"task.sync_interval":          viper.GetInt("task.sync_interval"),
+		"task.metrics_interval":       viper.GetInt("task.metrics_interval"),
+		"save.sync_interval":          viper.GetInt("save.sync_interval"),
+		"save.backup_interval":        viper.GetInt("save.backup_interval"),
+		"save.backup_verify_interval": viper.GetInt("save.backup_verify_interval"),
+		"servers":                     tool.ExtraServers(),
+	})
+	return string(signature)
+}
+
+// Reschedule 在配置重新加载后调用，间隔或服务器配置变化时重新创建间隔任务
+func Reschedule(db *bbolt.DB) {
+	rescheduleMu.Lock()
+	defer rescheduleMu.Unlock()
+
+	// 启动时的任务还未创建
+	if scheduleSignature == "" {
+		return
+	}
+	signature := intervalSignature()
+	if signature == scheduleSignature {
+		return
+	}
+	s := getScheduler()
+	s.RemoveByTags(intervalTag)
+	scheduleIntervalJobs(s, db, false)
+	// 定时公告的任务保存了创建时的服务器配置，新增或移除的服务器也需要更新公告
+	s.RemoveByTags(announcementsTag)
+	scheduleAnnouncements()
+	scheduleSignature = signature
+	logger.Info("任务间隔或服务器配置已修改, 已重新创建定时任务\n")
+}
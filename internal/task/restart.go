@@ -0,0 +1,336 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
+	"github.com/qycnet/palworld-server-tool-main/service"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+	"go.etcd.io/bbolt"
+)
+
+// 倒计时结束后，关闭服务器前的等待时间，单位秒
+const restartShutdownWait = 5
+
+var ErrNoRestart = errors.New("没有计划中的重启")
+
+// RestartStatus 计划中的重启
+type RestartStatus struct {
+	// 下一次重启的时间，没有计划时为空
+	Time *time.Time `json:"time"`
+	// 距离重启的秒数
+	Remaining int `json:"remaining"`
+	// 是否为通过接口手动安排的重启
+	Manual    bool   `json:"manual"`
+	Cron      string `json:"cron"`
+	Countdown []int  `json:"countdown"`
+}
+
+// restartState 一个服务器计划中的重启
+type restartState struct {
+	sync.Mutex
+	next   time.Time
+	manual bool
+	// 已经处理过的倒计时提醒数量
+	warned int
+	// 玩家是否已经收到过提醒
+	notified bool
+	// 计算 next 时使用的 cron 表达式
+	cron     string
+	schedule cron.Schedule
+}
+
+// 每个服务器的重启状态，按服务器 ID 保存
+var (
+	restartsMu sync.Mutex
+	restarts   = make(map[string]*restartState)
+)
+
+// getRestart 返回服务器的重启状态，第一次获取时从数据库中恢复计划中的重启
+func getRestart(srv *tool.Server) *restartState {
+	restartsMu.Lock()
+	defer restartsMu.Unlock()
+	restart, ok := restarts[srv.Id]
+	if !ok {
+		restart = &restartState{}
+		restart.load(srv, time.Now())
+		restarts[srv.Id] = restart
+	}
+	return restart
+}
+
+// load 从数据库中恢复计划中的重启，工具关闭期间已经错过的重启不再执行
+func (restart *restartState) load(srv *tool.Server, now time.Time) {
+	planned, err := service.GetPlannedRestart(srv.DB())
+	if err != nil {
+		if err != service.ErrNoRecord {
+			logger.Errorf("读取服务器 %s 计划中的重启失败: %v\n", srv.Id, err)
+		}
+		return
+	}
+	if !planned.Time.After(now) {
+		logger.Warnf("服务器 %s 计划在 %s 的重启已经错过, 不再执行\n", srv.Id, planned.Time.Format(time.DateTime))
+		return
+	}
+	if planned.Cron != "" {
+		schedule, err := cron.ParseStandard(planned.Cron)
+		if err != nil {
+			return
+		}
+		restart.schedule = schedule
+	}
+	restart.next = planned.Time
+	restart.manual = planned.Manual
+	restart.cron = planned.Cron
+	restart.resetWarned(restartCountdown(), remainingSeconds(restart.next, now))
+	restart.notified = planned.Notified
+}
+
+// save 保存计划中的重启，没有计划时删除保存的记录，需要持有锁
+func (restart *restartState) save(srv *tool.Server) {
+	var err error
+	if restart.next.IsZero() {
+		err = service.DeletePlannedRestart(srv.DB())
+	} else {
+		err = service.PutPlannedRestart(srv.DB(), database.PlannedRestart{
+			Time:     restart.next,
+			Manual:   restart.manual,
+			Cron:     restart.cron,
+			Notified: restart.notified,
+		})
+	}
+	if err != nil {
+		logger.Errorf("保存服务器 %s 计划中的重启失败: %v\n", srv.Id, err)
+	}
+}
+
+// restartCountdown 返回从大到小排列的倒计时提醒时间，单位秒
+func restartCountdown() []int {
+	countdown := make([]int, 0)
+	for _, seconds := range viper.GetIntSlice("task.restart_countdown") {
+		if seconds > 0 {
+			countdown = append(countdown, seconds)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(countdown)))
+	return countdown
+}
+
+// formatRemaining 将秒数格式化为 15m、1m30s、10s 的形式
+func formatRemaining(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	if seconds%60 == 0 {
+		return fmt.Sprintf("%dm", seconds/60)
+	}
+	return fmt.Sprintf("%dm%ds", seconds/60, seconds%60)
+}
+
+func restartMessage(seconds int) string {
+	return strings.ReplaceAll(viper.GetString("task.restart_message"), "{remaining}", formatRemaining(seconds))
+}
+
+func broadcastRestart(srv *tool.Server, message string) {
+	if err := srv.Broadcast(message); err != nil {
+		logger.Warnf("服务器 %s 广播失败, %s \n", srv.Id, err)
+	}
+}
+
+func remainingSeconds(next, now time.Time) int {
+	return int(math.Ceil(next.Sub(now).Seconds()))
+}
+
+// resetWarned 跳过比剩余时间更长的倒计时提醒，在重启时间变化后调用，需要持有锁
+func (restart *restartState) resetWarned(countdown []int, remaining int) {
+	restart.warned = 0
+	restart.notified = false
+	for restart.warned < len(countdown) && countdown[restart.warned] > remaining {
+		restart.warned++
+	}
+}
+
+// plan 根据服务器的 restart_cron 计算下一次重启时间，需要持有锁
+func (restart *restartState) plan(srv *tool.Server, now time.Time) {
+	restart.next = time.Time{}
+	restart.manual = false
+	restart.cron = srv.RestartCron
+	restart.schedule = nil
+	if restart.cron == "" {
+		return
+	}
+	schedule, err := cron.ParseStandard(restart.cron)
+	if err != nil {
+		logger.Errorf("服务器 %s 的 restart_cron 无效: %v\n", srv.Id, err)
+		return
+	}
+	restart.schedule = schedule
+	restart.next = schedule.Next(now)
+	restart.resetWarned(restartCountdown(), remainingSeconds(restart.next, now))
+}
+
+// RestartTask 检查默认服务器计划中的重启
+func RestartTask(db *bbolt.DB) {
+	ServerRestartTask(tool.DefaultServer(), db)
+}
+
+// ServerRestartTask 每秒检查一次服务器计划中的重启，按倒计时发送提醒，到时间后备份并关闭服务器
+func ServerRestartTask(srv *tool.Server, db *bbolt.DB) error {
+	now := time.Now()
+	countdown := restartCountdown()
+
+	restart := getRestart(srv)
+	restart.Lock()
+	// 配置中的 cron 表达式变化时重新计算
+	if restart.next.IsZero() || (!restart.manual && restart.cron != srv.RestartCron) {
+		planned := !restart.next.IsZero()
+		restart.plan(srv, now)
+		// 没有计划中的重启时每秒都会重新计算，只在计划变化时保存
+		if planned || !restart.next.IsZero() {
+			restart.save(srv)
+		}
+	}
+	if restart.next.IsZero() {
+		restart.Unlock()
+		return nil
+	}
+
+	remaining := remainingSeconds(restart.next, now)
+	// 同一时刻跨过多个提醒时只发送最后一个
+	warn := false
+	for restart.warned < len(countdown) && countdown[restart.warned] >= remaining {
+		restart.warned++
+		warn = true
+	}
+	if remaining <= 0 {
+		restart.next = time.Time{}
+		restart.manual = false
+		restart.save(srv)
+		restart.Unlock()
+		doRestart(srv, db)
+		return nil
+	}
+	if warn && !restart.notified {
+		restart.notified = true
+		restart.save(srv)
+	}
+	restart.Unlock()
+
+	if warn {
+		broadcastRestart(srv, restartMessage(remaining))
+	}
+	return nil
+}
+
+func doRestart(srv *tool.Server, db *bbolt.DB) {
+	logger.Infof("服务器 %s 开始计划重启...\n", srv.Id)
+	Emit(srv, webhook.EventServerRestart, "Scheduled restart started", nil)
+
+	// 重启前先保存世界并备份
+	if err := srv.Save(); err != nil {
+		logger.Warnf("无法保存世界: %v\n", err)
+	}
+	backup, err := srv.Backup(db)
+	if err != nil {
+		logger.Errorf("重启前备份失败: %v\n", err)
+		Emit(srv, webhook.EventBackupFailure, "Backup before restart failed: "+err.Error(), map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else {
+		logger.Infof("重启前备份到 %v %s\n", backup.Targets, backup.Path)
+	}
+
+	if err = srv.Shutdown(restartShutdownWait, restartMessage(restartShutdownWait)); err != nil {
+		logger.Errorf("无法关闭服务器: %v\n", err)
+	}
+}
+
+// GetRestartStatus 返回服务器计划中的重启
+func GetRestartStatus(srv *tool.Server) RestartStatus {
+	restart := getRestart(srv)
+	restart.Lock()
+	defer restart.Unlock()
+	status := RestartStatus{
+		Manual:    restart.manual,
+		Cron:      srv.RestartCron,
+		Countdown: restartCountdown(),
+	}
+	if !restart.next.IsZero() {
+		next := restart.next
+		status.Time = &next
+		status.Remaining = remainingSeconds(next, time.Now())
+	}
+	return status
+}
+
+// PlanRestart 安排服务器在 seconds 秒后重启，覆盖计划中的重启
+func PlanRestart(srv *tool.Server, seconds int) RestartStatus {
+	now := time.Now()
+	restart := getRestart(srv)
+	restart.Lock()
+	restart.next = now.Add(time.Duration(seconds) * time.Second)
+	restart.manual = true
+	restart.resetWarned(restartCountdown(), seconds)
+	restart.save(srv)
+	restart.Unlock()
+	return GetRestartStatus(srv)
+}
+
+// PostponeRestart 将服务器计划中的重启推迟 seconds 秒
+func PostponeRestart(srv *tool.Server, seconds int) (RestartStatus, error) {
+	restart := getRestart(srv)
+	restart.Lock()
+	if restart.next.IsZero() {
+		restart.Unlock()
+		return RestartStatus{}, ErrNoRestart
+	}
+	countdown := restartCountdown()
+	notified := restart.notified
+	restart.next = restart.next.Add(time.Duration(seconds) * time.Second)
+	remaining := remainingSeconds(restart.next, time.Now())
+	restart.resetWarned(countdown, remaining)
+	restart.notified = notified
+	restart.save(srv)
+	restart.Unlock()
+
+	// 玩家已经收到过提醒时，告知新的重启时间
+	if notified {
+		broadcastRestart(srv, restartMessage(remaining))
+	}
+	return GetRestartStatus(srv), nil
+}
+
+// CancelRestart 取消服务器计划中的重启，定时重启只跳过这一次
+func CancelRestart(srv *tool.Server) (RestartStatus, error) {
+	restart := getRestart(srv)
+	restart.Lock()
+	if restart.next.IsZero() {
+		restart.Unlock()
+		return RestartStatus{}, ErrNoRestart
+	}
+	notified := restart.notified
+	if restart.manual || restart.schedule == nil {
+		// 手动安排的重启取消后，重新按 cron 表达式计算
+		restart.plan(srv, time.Now())
+	} else {
+		restart.next = restart.schedule.Next(restart.next)
+		restart.resetWarned(restartCountdown(), remainingSeconds(restart.next, time.Now()))
+	}
+	restart.save(srv)
+	restart.Unlock()
+
+	if notified {
+		broadcastRestart(srv, viper.GetString("task.restart_cancel_message"))
+	}
+	return GetRestartStatus(srv), nil
+}
@@ -0,0 +1,58 @@
+package task
+
+import (
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/spf13/viper"
+	"go.etcd.io/bbolt"
+)
+
+type serverJob struct {
+	name     string
+	interval int
+	// 是否在启动时立即执行一次
+	runNow bool
+	fn     func(*tool.Server, *bbolt.DB) error
+}
+
+// 已经创建过任务的服务器，重新创建任务时只有新增的服务器立即执行一次
+var scheduledServers = make(map[string]bool)
+
+// scheduleServers 为 servers 中配置的每个服务器创建玩家同步、存档同步、备份、指标采集和重启任务
+func scheduleServers(s gocron.Scheduler, runNow bool) {
+	for _, srv := range tool.ExtraServers() {
+		db := srv.DB()
+		runServerNow := runNow || !scheduledServers[srv.Id]
+		scheduledServers[srv.Id] = true
+		jobs := []serverJob{
+			{"player_sync", viper.GetInt("task.sync_interval"), true, ServerPlayerSync},
+			{"sav_sync", srv.Server.Save.SyncInterval, true, ServerSavSync},
+			{"backup", srv.Server.Save.BackupInterval, true, ServerBackupTask},
+			{"backup_verify", srv.Server.Save.BackupVerifyInterval, false, ServerVerifyBackupTask},
+			{"metrics", viper.GetInt("task.metrics_interval"), false, ServerMetricsTask},
+			// 每秒检查计划中的重启，未设置 restart_cron 时也可以通过接口安排重启
+			{"restart", 1, false, ServerRestartTask},
+		}
+		for _, job := range jobs {
+			if job.interval <= 0 {
+				continue
+			}
+			if job.runNow && runServerNow {
+				go job.fn(srv, db)
+			}
+			_, err := s.NewJob(
+				gocron.DurationJob(time.Duration(job.interval)*time.Second),
+				gocron.NewTask(job.fn, srv, db),
+				gocron.WithName(job.name+":"+srv.Id),
+				gocron.WithTags(intervalTag, "server:"+srv.Id),
+				gocron.WithSingletonMode(gocron.LimitModeReschedule),
+			)
+			if err != nil {
+				logger.Errorf("%v\n", err)
+			}
+		}
+	}
+}
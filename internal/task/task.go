@@ -6,66 +6,137 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/event"
+	"github.com/qycnet/palworld-server-tool-main/internal/exporter"
 	"github.com/qycnet/palworld-server-tool-main/internal/system"
 
 	"github.com/go-co-op/gocron/v2"
 	"github.com/spf13/viper"
 	"github.com/qycnet/palworld-server-tool-main/internal/logger"
 	"github.com/qycnet/palworld-server-tool-main/internal/tool"
+	"github.com/qycnet/palworld-server-tool-main/internal/webhook"
 	"github.com/qycnet/palworld-server-tool-main/service"
 	"go.etcd.io/bbolt"
 )
 
 var s gocron.Scheduler
 
-func BackupTask(db *bbolt.DB) {
+// 检查封禁是否到期的间隔，单位秒
+const banExpireInterval = 60
+
+// 各服务器是否无法访问，只在状态变化时发送通知
+var unreachable sync.Map
+
+func serverUnreachable(id string) *atomic.Bool {
+	v, _ := unreachable.LoadOrStore(id, new(atomic.Bool))
+	return v.(*atomic.Bool)
+}
+
+// Emit 发送服务器的 webhook 事件，额外服务器的事件会附带服务器 ID 和名称
+func Emit(srv *tool.Server, eventType, message string, data map[string]interface{}) {
+	if !srv.IsDefault() {
+		name := srv.Name
+		if name == "" {
+			name = srv.Id
+		}
+		message = "[" + name + "] " + message
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		data["server_id"] = srv.Id
+	}
+	webhook.Emit(eventType, message, data)
+}
+
+func BackupTask(db *bbolt.DB) error {
+	return ServerBackupTask(tool.DefaultServer(), db)
+}
+
+// ServerBackupTask 备份指定服务器的存档
+func ServerBackupTask(srv *tool.Server, db *bbolt.DB) error {
 	// 记录日志，提示开始安排备份
 	logger.Info("开始备份...\n")
 
-	// 调用备份工具进行备份，并获取备份路径
-	path, err := tool.Backup()
+	// 调用备份工具进行备份，备份信息会保存到数据库中
+	backup, err := srv.Backup(db)
 	if err != nil {
 		// 如果备份过程中出现错误，记录错误日志并返回
 		logger.Errorf("%v\n", err)
-		return
+		Emit(srv, webhook.EventBackupFailure, "Backup failed: "+err.Error(), map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
 	}
-
-	// 将备份信息添加到数据库中
-	err = service.AddBackup(db, database.Backup{
-		BackupId: uuid.New().String(), // 生成新的备份ID
-		Path:     path,                // 设置备份路径
-		SaveTime: time.Now(),          // 设置备份时间
+	Emit(srv, webhook.EventBackupSuccess, "Backup "+backup.Path+" completed", map[string]interface{}{
+		"backup_id": backup.BackupId,
+		"path":      backup.Path,
+		"targets":   backup.Targets,
 	})
-	if err != nil {
-		// 如果向数据库添加备份信息时出现错误，记录错误日志并返回
-		logger.Errorf("%v\n", err)
-		return
-	}
 
 	// 记录日志，提示备份完成并显示备份路径
-	logger.Infof("自动备份到backups目录 %s\n", path)
-	
-		keepDays := viper.GetInt("save.backup_keep_days")
-	if keepDays == 0 {
-		keepDays = 7
-	}
-	err = tool.CleanOldBackups(db, keepDays)
+	logger.Infof("自动备份到 %v %s\n", backup.Targets, backup.Path)
+
+	// 按各存储目标的保留天数清理旧备份
+	err = srv.CleanOldBackups(db)
 	if err != nil {
 		logger.Errorf("无法清理旧备份: %v\n", err)
 	}
+	return err
+}
+
+func VerifyBackupTask(db *bbolt.DB) error {
+	return ServerVerifyBackupTask(tool.DefaultServer(), db)
 }
 
-func PlayerSync(db *bbolt.DB) {
+func ServerVerifyBackupTask(srv *tool.Server, db *bbolt.DB) error {
+	logger.Info("开始校验备份...\n")
+
+	broken, err := srv.VerifyBackups(db)
+	if err != nil {
+		logger.Errorf("无法校验备份: %v\n", err)
+		return err
+	}
+	if broken > 0 {
+		logger.Warnf("校验备份完成, %d 个备份已损坏\n", broken)
+		return fmt.Errorf("%d 个备份已损坏", broken)
+	}
+	logger.Info("校验备份完成\n")
+	return nil
+}
+
+// SessionTimeout 超过 3 个同步周期未更新的会话视为已中断
+func SessionTimeout() time.Duration {
+	return 3 * time.Duration(viper.GetInt("task.sync_interval")) * time.Second
+}
+
+func PlayerSync(db *bbolt.DB) error {
+	return ServerPlayerSync(tool.DefaultServer(), db)
+}
+
+// ServerPlayerSync 同步指定服务器的在线玩家，并处理会话、封禁和白名单
+func ServerPlayerSync(srv *tool.Server, db *bbolt.DB) error {
 	logger.Info("玩家信息同步...\n")
 	// 获取在线玩家列表
-	onlinePlayers, err := tool.ShowPlayers()
+	onlinePlayers, err := srv.ShowPlayers()
+	syncErr := err
 	if err != nil {
 		// 如果获取在线玩家列表出错，记录错误日志
 		logger.Errorf("获取在线玩家列表出错 %v\n", err)
+		if !serverUnreachable(srv.Id).Swap(true) {
+			Emit(srv, webhook.EventServerUnreachable, "Server is unreachable: "+err.Error(), map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	} else {
+		if serverUnreachable(srv.Id).Swap(false) {
+			Emit(srv, webhook.EventServerRecovered, "Server is reachable again", nil)
+		}
+		event.Publish(event.Event{Type: event.EventPlayers, Server: eventServer(srv), Data: onlinePlayers})
 	}
 	// 将在线玩家列表存入数据库
 	err = service.PutPlayersOnline(db, onlinePlayers)
@@ -75,18 +146,152 @@ func PlayerSync(db *bbolt.DB) {
 	}
 	logger.Info("玩家信息同步完成\n")
 
-	// 获取玩家日志记录的配置项
-	playerLogging := viper.GetBool("task.player_logging")
-	if playerLogging {
-		// 如果开启玩家日志记录，启动玩家日志记录协程
-		go PlayerLogging(onlinePlayers)
+	// 获取在线玩家失败时不更新会话，避免将所有玩家记为离线，只结束已经超时的会话
+	timeout := SessionTimeout()
+	if syncErr != nil {
+		if err = service.CloseStaleSessions(db, time.Now(), timeout); err != nil {
+			logger.Errorf("%v\n", err)
+		}
+	} else {
+		joined, left, err := service.SyncSessions(db, onlinePlayers, time.Now(), timeout)
+		if err != nil {
+			logger.Errorf("%v\n", err)
+		} else {
+			emitSessionEvents(srv, joined, left, len(onlinePlayers))
+			if viper.GetBool("task.player_logging") {
+				// 如果开启玩家日志记录，启动玩家日志记录协程
+				go PlayerLogging(srv, joined, left, len(onlinePlayers))
+			}
+		}
 	}
 
+	// 重新封禁仍在线的被封禁玩家，到期的封禁由封禁到期任务解除
+	go BanSync(srv, db, onlinePlayers)
+
 	// 获取是否踢除非白名单玩家的配置项
 	kickInterval := viper.GetBool("manage.kick_non_whitelist")
 	if kickInterval {
 		// 如果开启踢除非白名单玩家的功能，启动检查并踢除玩家的协程
-		go CheckAndKickPlayers(db, onlinePlayers)
+		go CheckAndKickPlayers(srv, db, onlinePlayers)
+	}
+	if syncErr == nil {
+		event.Publish(event.Event{Type: event.EventPlayerSync, Server: eventServer(srv), Data: map[string]interface{}{
+			"online_num": len(onlinePlayers),
+		}})
+	}
+	return syncErr
+}
+
+// eventServer 返回事件流中的服务器 ID，默认服务器为空
+func eventServer(srv *tool.Server) string {
+	if srv.IsDefault() {
+		return ""
+	}
+	return srv.Id
+}
+
+func emitSessionEvents(srv *tool.Server, joined, left []database.PlayerSession, onlineNum int) {
+	for _, session := range joined {
+		Emit(srv, webhook.EventPlayerJoin, fmt.Sprintf("%s joined the server (%d online)", session.Nickname, onlineNum), sessionData(session, onlineNum))
+	}
+	for _, session := range left {
+		Emit(srv, webhook.EventPlayerLeave, fmt.Sprintf("%s left the server (%d online)", session.Nickname, onlineNum), sessionData(session, onlineNum))
+	}
+}
+
+func sessionData(session database.PlayerSession, onlineNum int) map[string]interface{} {
+	return map[string]interface{}{
+		"player_uid": session.PlayerUid,
+		"nickname":   session.Nickname,
+		"steam_id":   session.SteamId,
+		"online_num": onlineNum,
+	}
+}
+
+// BanExpireTask 解除所有服务器上到期的封禁和全局封禁，不依赖玩家同步任务，
+// 因此 sync_interval 设置为0时封禁也会按时解除
+func BanExpireTask() {
+	for _, srv := range tool.Servers() {
+		ExpireBans(srv, srv.DB())
+	}
+	expireGlobalBans()
+}
+
+// ExpireBans 解除服务器上已经到期的临时封禁
+func ExpireBans(srv *tool.Server, db *bbolt.DB) {
+	bans, err := service.ListBans(db)
+	if err != nil {
+		logger.Errorf("%v\n", err)
+		return
+	}
+
+	now := time.Now()
+	for _, ban := range bans {
+		if ban.ExpiresAt.IsZero() || !now.After(ban.ExpiresAt) {
+			continue
+		}
+		// 仍有生效的全局封禁时只删除服务器自身的封禁记录，保留游戏内的封禁
+		global := GlobalBanActive(ban.SteamId)
+		if !global {
+			if err := srv.UnBanPlayer(fmt.Sprintf("steam_%s", ban.SteamId)); err != nil {
+				logger.Warnf("解除 %s 的封禁失败, %s \n", ban.Nickname, err)
+				continue
+			}
+		}
+		if err := service.DeleteBan(db, ban.PlayerUid); err != nil {
+			logger.Errorf("%v\n", err)
+			continue
+		}
+		if global {
+			logger.Infof("%s 的封禁已到期, 全局封禁仍然生效\n", ban.Nickname)
+			continue
+		}
+		logger.Infof("%s 的封禁已到期, 已解除封禁\n", ban.Nickname)
+		Emit(srv, webhook.EventPlayerUnban, ban.Nickname+"'s ban has expired", banData(ban))
+	}
+}
+
+// BanSync 重新封禁仍然在线的被封禁玩家
+func BanSync(srv *tool.Server, db *bbolt.DB, players []database.OnlinePlayer) {
+	globalBanSync(srv, players)
+
+	bans, err := service.ListBans(db)
+	if err != nil {
+		logger.Errorf("%v\n", err)
+		return
+	}
+
+	online := make(map[string]bool, len(players))
+	for _, player := range players {
+		online[player.PlayerUid] = true
+	}
+
+	now := time.Now()
+	for _, ban := range bans {
+		// 到期的封禁等待封禁到期任务解除
+		if !ban.ExpiresAt.IsZero() && now.After(ban.ExpiresAt) {
+			continue
+		}
+		// 被封禁的玩家仍然在线，说明服务器的封禁列表已被重置，重新封禁
+		if online[ban.PlayerUid] {
+			if err := srv.BanPlayer(fmt.Sprintf("steam_%s", ban.SteamId)); err != nil {
+				logger.Warnf("重新封禁 %s 失败, %s \n", ban.Nickname, err)
+				continue
+			}
+			logger.Warnf("重新封禁 %s 成功 \n", ban.Nickname)
+			Emit(srv, webhook.EventPlayerBan, ban.Nickname+" was banned again after rejoining", banData(ban))
+		}
+	}
+}
+
+func banData(ban database.Ban) map[string]interface{} {
+	return map[string]interface{}{
+		"player_uid": ban.PlayerUid,
+		"nickname":   ban.Nickname,
+		"steam_id":   ban.SteamId,
+		"reason":     ban.Reason,
+		"operator":   ban.Operator,
+		"expires_at": ban.ExpiresAt,
 	}
 }
 
@@ -104,48 +309,26 @@ func isPlayerWhitelisted(player database.OnlinePlayer, whitelist []database.Play
 	return false
 }
 
-var playerCache map[string]string
-var firstPoll = true
-
-func PlayerLogging(players []database.OnlinePlayer) {
+func PlayerLogging(srv *tool.Server, joined, left []database.PlayerSession, onlineNum int) {
 	// 获取配置文件中定义的登录和登出消息
 	loginMsg := viper.GetString("task.player_login_message")
 	logoutMsg := viper.GetString("task.player_logout_message")
 
-	// 创建一个临时map，用于存储玩家UID和昵称的映射关系
-	tmp := make(map[string]string, len(players))
-	// 遍历玩家列表，将玩家UID和昵称存储到临时map中
-	for _, player := range players {
-		// 如果玩家UID不为空，则存储到临时map中
-		if player.PlayerUid != "" {
-			tmp[player.PlayerUid] = player.Nickname
-		}
+	// 广播登录消息
+	for _, session := range joined {
+		broadcastVariableMessage(srv, loginMsg, session.Nickname, onlineNum)
 	}
-
-	// 如果不是第一次轮询
-	if !firstPoll {
-		// 遍历临时map，如果玩家在playerCache中不存在，则广播登录消息
-		for id, name := range tmp {
-			if _, ok := playerCache[id]; !ok {
-				// 广播登录消息
-				BroadcastVariableMessage(loginMsg, name, len(players))
-			}
-		}
-		// 遍历playerCache，如果玩家在临时map中不存在，则广播登出消息
-		for id, name := range playerCache {
-			if _, ok := tmp[id]; !ok {
-				// 广播登出消息
-				BroadcastVariableMessage(logoutMsg, name, len(players))
-			}
-		}
+	// 广播登出消息
+	for _, session := range left {
+		broadcastVariableMessage(srv, logoutMsg, session.Nickname, onlineNum)
 	}
-	// 设置firstPoll为false，表示已经进行过一次轮询
-	firstPoll = false
-	// 更新playerCache为最新的临时map
-	playerCache = tmp
 }
 
 func BroadcastVariableMessage(message string, username string, onlineNum int) {
+	broadcastVariableMessage(tool.DefaultServer(), message, username, onlineNum)
+}
+
+func broadcastVariableMessage(srv *tool.Server, message string, username string, onlineNum int) {
 	// 将消息中的"{username}"替换为实际的用户名
 	message = strings.ReplaceAll(message, "{username}", username)
 	// 将消息中的"{online_num}"替换为实际的在线人数
@@ -154,7 +337,7 @@ func BroadcastVariableMessage(message string, username string, onlineNum int) {
 	arr := strings.Split(message, "\n")
 	for _, msg := range arr {
 		// 广播消息
-		err := tool.Broadcast(msg)
+		err := srv.Broadcast(msg)
 		if err != nil {
 			// 如果广播失败，记录警告日志
 			logger.Warnf("广播失败, %s \n", err)
@@ -165,12 +348,18 @@ func BroadcastVariableMessage(message string, username string, onlineNum int) {
 	}
 }
 
-func CheckAndKickPlayers(db *bbolt.DB, players []database.OnlinePlayer) {
+func CheckAndKickPlayers(srv *tool.Server, db *bbolt.DB, players []database.OnlinePlayer) {
 	// 获取白名单
 	whitelist, err := service.ListWhitelist(db)
 	if err != nil {
 		logger.Errorf("%v\n", err)
 	}
+	// 全局白名单中的玩家可以进入所有服务器
+	globalWhitelist, err := service.ListGlobalWhitelist(database.GetDB())
+	if err != nil {
+		logger.Errorf("%v\n", err)
+	}
+	whitelist = append(whitelist, globalWhitelist...)
 
 	// 遍历所有在线玩家
 	for _, player := range players {
@@ -185,7 +374,7 @@ func CheckAndKickPlayers(db *bbolt.DB, players []database.OnlinePlayer) {
 				continue
 			}
 			// 踢出玩家
-			err := tool.KickPlayer(fmt.Sprintf("steam_%s", identifier))
+			err := srv.KickPlayer(fmt.Sprintf("steam_%s", identifier))
 			// 如果踢出失败
 			if err != nil {
 				// 日志记录：踢出失败，记录错误信息
@@ -194,46 +383,140 @@ func CheckAndKickPlayers(db *bbolt.DB, players []database.OnlinePlayer) {
 			}
 			// 日志记录：踢出成功
 			logger.Warnf("踢 %s 成功 \n", player.Nickname)
+			Emit(srv, webhook.EventWhitelistKick, player.Nickname+" was kicked for not being whitelisted", map[string]interface{}{
+				"player_uid": player.PlayerUid,
+				"nickname":   player.Nickname,
+				"steam_id":   player.SteamId,
+			})
 		}
 	}
 	// 日志记录：白名单检查完成
 	logger.Info("检查白名单完成\n")
 }
 
-func SavSync() {
+func SavSync(db *bbolt.DB) error {
+	return ServerSavSync(tool.DefaultServer(), db)
+}
+
+// ServerSavSync 解析指定服务器的存档并保存玩家和公会数据
+func ServerSavSync(srv *tool.Server, db *bbolt.DB) error {
 	// 记录日志：调度Sav同步...
 	logger.Info("调度Sav同步...\n")
 
-	// 解码viper配置文件中的保存路径
-	err := tool.Decode(viper.GetString("save.path"))
+	// 解码配置文件中的存档路径
+	err := tool.Decode(db, srv.Server.Save.Path)
 	if err != nil {
 		// 记录错误日志
 		logger.Errorf("%v\n", err)
+		Emit(srv, webhook.EventSavSyncFailure, "Sav sync failed: "+err.Error(), map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
 	}
+	exporter.SetLastSavSync(srv.Id, time.Now())
+	event.Publish(event.Event{Type: event.EventSavSync, Server: eventServer(srv)})
 
 	// 记录日志：Sav同步完成
 	logger.Info("Sav同步完成\n")
+	return nil
+}
+
+// CleanAuditTask 删除超过 task.audit_keep_days 的审计日志，设置为0时永久保留
+func CleanAuditTask(db *bbolt.DB) {
+	keepDays := viper.GetInt("task.audit_keep_days")
+	if keepDays <= 0 {
+		return
+	}
+	if err := service.CleanAuditLogs(db, time.Now().AddDate(0, 0, -keepDays)); err != nil {
+		logger.Errorf("清理审计日志时出错: %v\n", err)
+	}
 }
 
 func Schedule(db *bbolt.DB) {
 	// 获取调度器实例
 	s := getScheduler()
 
+	// 创建同步、备份和指标采集任务
+	rescheduleMu.Lock()
+	scheduleIntervalJobs(s, db, true)
+	scheduleSignature = intervalSignature()
+	rescheduleMu.Unlock()
+
+	// 创建限制缓存目录大小的任务
+	_, err := s.NewJob(
+		gocron.DurationJob(300*time.Second),
+		gocron.NewTask(system.LimitCacheDir, filepath.Join(os.TempDir(), "palworldsav-"), 5),
+		gocron.WithName("limit_cache_dir"),
+	)
+	if err != nil {
+		// 记录错误日志
+		logger.Errorf("%v\n", err)
+	}
+
+	// 创建解除到期封禁的任务
+	_, err = s.NewJob(
+		gocron.DurationJob(banExpireInterval*time.Second),
+		gocron.NewTask(BanExpireTask),
+		gocron.WithName("ban_expire"),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.Errorf("%v\n", err)
+	}
+
+	// 创建清理过期审计日志的任务
+	_, err = s.NewJob(
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(CleanAuditTask, db),
+		gocron.WithName("audit_clean"),
+	)
+	if err != nil {
+		logger.Errorf("%v\n", err)
+	}
+
+	// 创建检查计划重启的任务，备份期间跳过后续的检查
+	_, err = s.NewJob(
+		gocron.DurationJob(time.Second),
+		gocron.NewTask(RestartTask, db),
+		gocron.WithName("restart"),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		logger.Errorf("%v\n", err)
+	}
+
+	// 注册已保存的定时公告
+	scheduleAnnouncements()
+
+	// 启动调度器
+	s.Start()
+}
+
+// scheduleIntervalJobs 创建按配置的间隔执行的任务，这些任务带有 intervalTag，配置修改后重新创建
+func scheduleIntervalJobs(s gocron.Scheduler, db *bbolt.DB, runNow bool) {
 	// 从配置文件中获取玩家同步间隔时间
 	playerSyncInterval := time.Duration(viper.GetInt("task.sync_interval"))
 	// 从配置文件中获取保存同步间隔时间
 	savSyncInterval := time.Duration(viper.GetInt("save.sync_interval"))
 	// 从配置文件中获取备份间隔时间
 	backupInterval := time.Duration(viper.GetInt("save.backup_interval"))
+	// 从配置文件中获取备份校验间隔时间
+	backupVerifyInterval := time.Duration(viper.GetInt("save.backup_verify_interval"))
+	// 从配置文件中获取服务器指标采集间隔时间
+	metricsInterval := time.Duration(viper.GetInt("task.metrics_interval"))
 
 	// 如果玩家同步间隔时间大于0
 	if playerSyncInterval > 0 {
-		// 启动玩家同步任务
-		go PlayerSync(db)
+		if runNow {
+			// 启动玩家同步任务
+			go PlayerSync(db)
+		}
 		// 创建玩家同步任务
 		_, err := s.NewJob(
 			gocron.DurationJob(playerSyncInterval*time.Second),
 			gocron.NewTask(PlayerSync, db),
+			gocron.WithName("player_sync"),
+			gocron.WithTags(intervalTag),
 		)
 		if err != nil {
 			// 记录错误日志
@@ -243,12 +526,16 @@ func Schedule(db *bbolt.DB) {
 
 	// 如果保存同步间隔时间大于0
 	if savSyncInterval > 0 {
-		// 启动保存同步任务
-		go SavSync()
+		if runNow {
+			// 启动保存同步任务
+			go SavSync(db)
+		}
 		// 创建保存同步任务
 		_, err := s.NewJob(
 			gocron.DurationJob(savSyncInterval*time.Second),
-			gocron.NewTask(SavSync),
+			gocron.NewTask(SavSync, db),
+			gocron.WithName("sav_sync"),
+			gocron.WithTags(intervalTag),
 		)
 		if err != nil {
 			// 记录错误日志
@@ -258,12 +545,16 @@ func Schedule(db *bbolt.DB) {
 
 	// 如果备份间隔时间大于0
 	if backupInterval > 0 {
-		// 启动备份任务
-		go BackupTask(db)
+		if runNow {
+			// 启动备份任务
+			go BackupTask(db)
+		}
 		// 创建备份任务
 		_, err := s.NewJob(
 			gocron.DurationJob(backupInterval*time.Second),
 			gocron.NewTask(BackupTask, db),
+			gocron.WithName("backup"),
+			gocron.WithTags(intervalTag),
 		)
 		if err != nil {
 			// 记录错误日志
@@ -271,18 +562,35 @@ func Schedule(db *bbolt.DB) {
 		}
 	}
 
-	// 创建限制缓存目录大小的任务
-	_, err := s.NewJob(
-		gocron.DurationJob(300*time.Second),
-		gocron.NewTask(system.LimitCacheDir, filepath.Join(os.TempDir(), "palworldsav-"), 5),
-	)
-	if err != nil {
-		// 记录错误日志
-		logger.Errorf("%v\n", err)
+	// 如果备份校验间隔时间大于0，创建备份校验任务
+	if backupVerifyInterval > 0 {
+		_, err := s.NewJob(
+			gocron.DurationJob(backupVerifyInterval*time.Second),
+			gocron.NewTask(VerifyBackupTask, db),
+			gocron.WithName("backup_verify"),
+			gocron.WithTags(intervalTag),
+		)
+		if err != nil {
+			logger.Errorf("%v\n", err)
+		}
 	}
 
-	// 启动调度器
-	s.Start()
+	// 为额外的服务器创建同步和备份任务
+	scheduleServers(s, runNow)
+
+	// 如果指标采集间隔时间大于0，创建服务器指标采集任务
+	if metricsInterval > 0 {
+		_, err := s.NewJob(
+			gocron.DurationJob(metricsInterval*time.Second),
+			gocron.NewTask(MetricsTask, db),
+			gocron.WithName("metrics"),
+			gocron.WithTags(intervalTag),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			logger.Errorf("%v\n", err)
+		}
+	}
 }
 
 func Shutdown() {
@@ -299,7 +607,7 @@ func Shutdown() {
 
 func initScheduler() gocron.Scheduler {
 	// 创建一个新的调度器实例
-	s, err := gocron.NewScheduler()
+	s, err := gocron.NewScheduler(gocron.WithMonitor(exporter.Monitor{}))
 	if err != nil {
 		// 如果创建调度器实例时出错，记录错误信息
 		logger.Errorf("%v\n", err)
@@ -312,7 +620,7 @@ func getScheduler() gocron.Scheduler {
 	// 如果 s 为 nil
 	if s == nil {
 		// 初始化调度器
-		return initScheduler()
+		s = initScheduler()
 	}
 	// 返回 s
 	return s
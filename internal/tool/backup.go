@@ -0,0 +1,355 @@
+package tool
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/storage"
+	"github.com/qycnet/palworld-server-tool-main/service"
+	"go.etcd.io/bbolt"
+)
+
+// 备份、删除和清理会读写同一批存档对象，需要串行执行
+var backupMutex sync.Mutex
+
+// objectName 返回文件内容在存储目标中的路径，相同内容只保存一份
+func objectName(sum string) string {
+	return "objects/" + sum[:2] + "/" + sum
+}
+
+// hashDir 计算目录中每个文件的 SHA-256，返回按文件名排序的清单
+func hashDir(dir string) ([]database.BackupFile, error) {
+	files := make([]database.BackupFile, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, database.BackupFile{
+			Name:   strings.ReplaceAll(relPath, string(os.PathSeparator), "/"),
+			Size:   info.Size(),
+			Sha256: sum,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// targetNames 返回保存了该备份的存储目标名称，旧版本的备份没有记录，均保存在本地
+func targetNames(backup database.Backup) []string {
+	if len(backup.Targets) == 0 && len(backup.Files) == 0 {
+		return []string{storage.DefaultTarget}
+	}
+	return backup.Targets
+}
+
+// hasTarget 判断备份是否保存在指定的存储目标中
+func hasTarget(backup database.Backup, name string) bool {
+	for _, target := range targetNames(backup) {
+		if target == name {
+			return true
+		}
+	}
+	return false
+}
+
+// referencedObjects 返回存储目标中仍被其他备份引用的对象
+func referencedObjects(backups []database.Backup, target string, excludeId string) map[string]bool {
+	objects := make(map[string]bool)
+	for _, backup := range backups {
+		if backup.BackupId == excludeId || !hasTarget(backup, target) {
+			continue
+		}
+		for _, file := range backup.Files {
+			objects[file.Sha256] = true
+		}
+	}
+	return objects
+}
+
+// putObjects 上传存储目标中尚不存在的文件。已被其他备份引用的对象会先检查是否仍在存储目标中，
+// 被手动删除或大小不一致的对象重新上传
+func putObjects(target storage.Target, dir string, files []database.BackupFile, referenced map[string]bool) error {
+	done := make(map[string]bool, len(files))
+	for _, file := range files {
+		if done[file.Sha256] {
+			continue
+		}
+		name := objectName(file.Sha256)
+		if referenced[file.Sha256] {
+			size, err := target.Size(name)
+			if err == nil && (size < 0 || size == file.Size) {
+				done[file.Sha256] = true
+				continue
+			}
+			if err != nil && !errors.Is(err, storage.ErrNotExist) {
+				return err
+			}
+			logger.Warnf("%s 中缺少 %s, 重新上传\n", target.Name, name)
+		}
+		if err := putFile(target, name, filepath.Join(dir, filepath.FromSlash(file.Name))); err != nil {
+			return err
+		}
+		done[file.Sha256] = true
+	}
+	return nil
+}
+
+// deleteFromTarget 从存储目标中删除备份，只删除不再被其他备份引用的对象
+func deleteFromTarget(target storage.Target, backup database.Backup, backups []database.Backup) error {
+	// 旧版本的完整 zip 备份
+	if len(backup.Files) == 0 {
+		return target.Delete(backup.Path)
+	}
+	referenced := referencedObjects(backups, target.Name, backup.BackupId)
+	for _, file := range backup.Files {
+		if referenced[file.Sha256] {
+			continue
+		}
+		if err := target.Delete(objectName(file.Sha256)); err != nil {
+			return err
+		}
+		referenced[file.Sha256] = true
+	}
+	return nil
+}
+
+// getObject 从第一个可用的存储目标读取对象
+func getObject(targets []storage.Target, name string) (io.ReadCloser, error) {
+	err := storage.ErrNotExist
+	for _, target := range targets {
+		var r io.ReadCloser
+		r, err = target.Get(name)
+		if err == nil {
+			return r, nil
+		}
+		logger.Warnf("无法从 %s 读取 %s: %s\n", target.Name, name, err)
+	}
+	return nil, err
+}
+
+// writeZip 根据清单从存储目标读取各个文件，重新组装为完整的 zip
+func writeZip(w io.Writer, targets []storage.Target, backup database.Backup) error {
+	archive := zip.NewWriter(w)
+	for _, file := range backup.Files {
+		header := &zip.FileHeader{
+			Name:     file.Name,
+			Method:   zip.Deflate,
+			Modified: backup.SaveTime,
+		}
+		header.SetMode(0644)
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		r, err := getObject(targets, objectName(file.Sha256))
+		if err != nil {
+			return errors.New("无法读取 " + file.Name + ": " + err.Error())
+		}
+		// 写入的同时计算校验和，避免把损坏的文件当作正常备份
+		h := sha256.New()
+		_, err = io.Copy(io.MultiWriter(writer, h), r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != file.Sha256 {
+			return errors.New(file.Name + " 校验和不一致")
+		}
+	}
+	return archive.Close()
+}
+
+// verifyCache 一次校验中已经读取过的对象，键为存储目标名称和 SHA-256，值为校验结果。
+// 多个备份引用同一对象时只读取一次
+type verifyCache map[string]error
+
+// verifyTarget 校验存储目标中的备份，增量备份逐个比对大小和 SHA-256，完整 zip 备份检查每个条目的 CRC
+func verifyTarget(target storage.Target, backup database.Backup, cache verifyCache) error {
+	if len(backup.Files) == 0 {
+		return verifyZip(target, backup.Path)
+	}
+	for _, file := range backup.Files {
+		key := target.Name + "/" + file.Sha256
+		err, ok := cache[key]
+		if !ok {
+			err = verifyObject(target, file)
+			cache[key] = err
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// verifyObject 读取存储目标中的对象，比对大小和 SHA-256
+func verifyObject(target storage.Target, file database.BackupFile) error {
+	r, err := target.Get(objectName(file.Sha256))
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	if size != file.Size {
+		return fmt.Errorf("大小不一致, 应为 %d, 实际为 %d", file.Size, size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != file.Sha256 {
+		return errors.New("校验和不一致")
+	}
+	return nil
+}
+
+func verifyZip(target storage.Target, name string) error {
+	r, err := target.Get(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// zip 需要随机读取，先保存到临时文件
+	file, err := os.CreateTemp("", "palworldsav-verify-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	size, err := io.Copy(file, r)
+	if err != nil {
+		return err
+	}
+
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return err
+	}
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %s", entry.Name, err)
+		}
+		// 读取到末尾时会检查 CRC32
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// VerifyBackup 校验备份在每个存储目标中是否完整，并将结果保存到备份记录中
+func (s *Server) VerifyBackup(db *bbolt.DB, backupId string) (database.Backup, error) {
+	return s.verifyBackup(db, backupId, make(verifyCache))
+}
+
+// verifyBackup 读取存储目标时不持有 backupMutex，避免远程存储较慢时阻塞备份和删除。
+// 删除备份只会删除不再被其他备份引用的对象，因此校验期间对象不会被删除
+func (s *Server) verifyBackup(db *bbolt.DB, backupId string, cache verifyCache) (database.Backup, error) {
+	backup, err := service.GetBackup(db, backupId)
+	if err != nil {
+		return database.Backup{}, err
+	}
+	targets, err := s.backupTargets(backup)
+	if err != nil {
+		return database.Backup{}, err
+	}
+
+	errs := make([]string, 0)
+	if len(targets) == 0 {
+		errs = append(errs, "保存该备份的存储目标均已不在配置中")
+	}
+	for _, target := range targets {
+		if err := verifyTarget(target, backup, cache); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", target.Name, err))
+		}
+	}
+
+	// 重新读取记录后再保存结果，校验期间被删除的备份返回 ErrNoRecord
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+	backup, err = service.GetBackup(db, backupId)
+	if err != nil {
+		return database.Backup{}, err
+	}
+	backup.VerifyTime = time.Now()
+	backup.Status = database.BackupStatusOk
+	backup.VerifyError = ""
+	if len(errs) > 0 {
+		backup.Status = database.BackupStatusBroken
+		backup.VerifyError = strings.Join(errs, "; ")
+	}
+	if err = service.AddBackup(db, backup); err != nil {
+		return database.Backup{}, err
+	}
+	return backup, nil
+}
+
+// VerifyBackups 校验所有备份，返回损坏的备份数量
+func (s *Server) VerifyBackups(db *bbolt.DB) (int, error) {
+	backups, err := service.ListBackups(db, time.Time{}, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	broken := 0
+	cache := make(verifyCache)
+	for _, backup := range backups {
+		backup, err = s.verifyBackup(db, backup.BackupId, cache)
+		if err != nil {
+			// 校验期间被删除的备份直接跳过
+			if err == service.ErrNoRecord {
+				continue
+			}
+			return broken, err
+		}
+		if backup.Status == database.BackupStatusBroken {
+			logger.Warnf("备份 %s 已损坏: %s\n", backup.Path, backup.VerifyError)
+			broken++
+		}
+	}
+	return broken, nil
+}
@@ -0,0 +1,63 @@
+package tool
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type cacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]cacheEntry)
+	// 合并同时进行的相同请求，只向游戏服务器发送一次
+	apiGroup singleflight.Group
+)
+
+// cachedApi 在 rest.cache_ttl 内复用 GET 请求的结果
+func (s *Server) cachedApi(api string) ([]byte, error) {
+	key := s.Id + api
+	cacheMu.Lock()
+	entry, ok := cache[key]
+	cacheMu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.data, nil
+	}
+
+	v, err, _ := apiGroup.Do(key, func() (interface{}, error) {
+		data, err := s.callApi("GET", api, nil)
+		if err != nil {
+			return nil, err
+		}
+		if ttl := s.Rest.CacheTtl; ttl > 0 {
+			cacheMu.Lock()
+			cache[key] = cacheEntry{
+				data:    data,
+				expires: time.Now().Add(time.Duration(ttl) * time.Second),
+			}
+			cacheMu.Unlock()
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// clearCache 修改服务器状态后清除该服务器的缓存，避免返回过期的玩家列表等数据
+func (s *Server) clearCache() {
+	cacheMu.Lock()
+	for key := range cache {
+		if strings.HasPrefix(key, s.Id+"/") {
+			delete(cache, key)
+		}
+	}
+	cacheMu.Unlock()
+}
@@ -0,0 +1,103 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/source"
+)
+
+var (
+	ErrNotContainer = errors.New("服务器的 save.path 不是 docker:// 或 k8s:// 地址，无法控制服务器容器")
+	ErrPodStartStop = errors.New("k8s 不支持单独启动或停止 Pod，请使用重启重建 Pod")
+)
+
+// container 解析服务器 save.path 中的容器地址，返回 docker 容器 ID 或 k8s 的 Pod 信息
+func (s *Server) container() (containerId, namespace, podName, container string, err error) {
+	file := s.Server.Save.Path
+	if strings.HasPrefix(file, "k8s://") {
+		namespace, podName, container, _, err = source.ParseK8sAddress(file)
+		if err != nil {
+			return "", "", "", "", errors.New("解析 K8s 地址时出错: " + err.Error())
+		}
+		if container == "" {
+			return "", "", "", "", source.ErrContainerEmpty
+		}
+		return "", namespace, podName, container, nil
+	} else if strings.HasPrefix(file, "docker://") {
+		containerId, _, err = source.ParseDockerAddress(file)
+		if err != nil {
+			return "", "", "", "", errors.New("解析 docker 地址时出错: " + err.Error())
+		}
+		return containerId, "", "", "", nil
+	}
+	return "", "", "", "", ErrNotContainer
+}
+
+// saveBeforeStop 停止容器前先让服务器保存世界，失败时只记录日志
+func (s *Server) saveBeforeStop() {
+	if err := s.Save(); err != nil {
+		logger.Warnf("服务器 %s 无法保存世界: %v\n", s.Id, err)
+	}
+}
+
+func (s *Server) StartContainer() error {
+	containerId, _, _, _, err := s.container()
+	if err != nil {
+		return err
+	}
+	if containerId == "" {
+		return ErrPodStartStop
+	}
+	return source.StartContainer(containerId)
+}
+
+func (s *Server) StopContainer() error {
+	containerId, _, _, _, err := s.container()
+	if err != nil {
+		return err
+	}
+	if containerId == "" {
+		return ErrPodStartStop
+	}
+	s.saveBeforeStop()
+	return source.StopContainer(containerId)
+}
+
+// RestartContainer 重启 docker 容器，或删除 Pod 由控制器重新创建
+func (s *Server) RestartContainer() error {
+	containerId, namespace, podName, _, err := s.container()
+	if err != nil {
+		return err
+	}
+	s.saveBeforeStop()
+	if containerId == "" {
+		return source.RestartPod(namespace, podName)
+	}
+	return source.RestartContainer(containerId)
+}
+
+func (s *Server) ContainerUsage() (source.ResourceUsage, error) {
+	containerId, namespace, podName, container, err := s.container()
+	if err != nil {
+		return source.ResourceUsage{}, err
+	}
+	if containerId == "" {
+		return source.PodUsage(namespace, podName, container)
+	}
+	return source.ContainerUsage(containerId)
+}
+
+func (s *Server) ContainerLogs(ctx context.Context, tail int, follow bool) (io.ReadCloser, error) {
+	containerId, namespace, podName, container, err := s.container()
+	if err != nil {
+		return nil, err
+	}
+	if containerId == "" {
+		return source.PodLogs(ctx, namespace, podName, container, tail, follow)
+	}
+	return source.ContainerLogs(ctx, containerId, tail, follow)
+}
@@ -3,21 +3,20 @@ package tool
 import (
 	"encoding/base64"
 
-	"github.com/spf13/viper"
 	"github.com/qycnet/palworld-server-tool-main/internal/executor"
 	"github.com/qycnet/palworld-server-tool-main/internal/logger"
 )
 
-func executeCommand(command string) (*executor.Executor, string, error) {
+func (s *Server) executeCommand(command string) (*executor.Executor, string, error) {
 	// 判断是否使用Base64编码
-	useBase64 := viper.GetBool("rcon.use_base64")
+	useBase64 := s.Rcon.UseBase64
 
 	// 创建Executor实例
 	exec, err := executor.NewExecutor(
-		viper.GetString("rcon.address"),  // 获取rcon地址
-		viper.GetString("rcon.password"), // 获取rcon密码
-		viper.GetInt("rcon.timeout"),      // 获取rcon超时时间
-		true)                              // 启用调试模式
+		s.Rcon.Address,  // 获取rcon地址
+		s.Rcon.Password, // 获取rcon密码
+		s.Rcon.Timeout,  // 获取rcon超时时间
+		true)            // 启用调试模式
 	if err != nil {
 		return nil, "", err
 	}
@@ -48,9 +47,9 @@ func executeCommand(command string) (*executor.Executor, string, error) {
 	return exec, response, nil
 }
 
-func CustomCommand(command string) (string, error) {
+func (s *Server) CustomCommand(command string) (string, error) {
 	// 执行命令
-	exec, response, err := executeCommand(command)
+	exec, response, err := s.executeCommand(command)
 	if err != nil {
 		// 如果执行命令出错，则返回错误
 		return "", err
@@ -2,7 +2,9 @@ package tool
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,36 +15,35 @@ import (
 
 	"github.com/qycnet/palworld-server-tool-main/internal/logger"
 
-	"github.com/spf13/viper"
 	"github.com/qycnet/palworld-server-tool-main/internal/database"
 )
 
 var client = &http.Client{}
 
-func callApi(method string, api string, param []byte) ([]byte, error) {
-
-	// 从配置文件中获取REST服务的地址
-	addr := viper.GetString("rest.address")
-	// 从配置文件中获取REST服务的用户名
-	user := viper.GetString("rest.username")
-	// 从配置文件中获取REST服务的密码
-	pass := viper.GetString("rest.password")
-	// 从配置文件中获取REST服务的超时时间
-	timeout := viper.GetInt("rest.timeout")
+func (s *Server) callApi(method string, api string, param []byte) ([]byte, error) {
+	// 踢出、封禁等操作会改变服务器状态
+	if method != http.MethodGet {
+		defer s.clearCache()
+	}
 
 	// 将API地址和基地址拼接成完整的URL
-	api, err := url.JoinPath(addr, api)
+	api, err := url.JoinPath(s.Rest.Address, api)
 	if err != nil {
 		return nil, err
 	}
 
+	// 不同服务器的超时时间可能不同，按请求设置超时
+	ctx := context.Background()
+	if s.Rest.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Rest.Timeout)*time.Second)
+		defer cancel()
+	}
+
 	// 创建HTTP请求
-	req, _ := http.NewRequest(method, api, bytes.NewReader(param))
+	req, _ := http.NewRequestWithContext(ctx, method, api, bytes.NewReader(param))
 	// 设置HTTP请求的基本认证信息
-	req.SetBasicAuth(user, pass)
-
-	// 设置HTTP客户端的超时时间
-	client.Timeout = time.Duration(timeout) * time.Second
+	req.SetBasicAuth(s.Rest.Username, s.Rest.Password)
 	// 发送HTTP请求
 	resp, err := client.Do(req)
 	if err != nil {
@@ -70,9 +71,9 @@ type ResponseInfo struct {
 	Worldguid string `json:"worldguid"`
 }
 
-func Info() (map[string]string, error) {
+func (s *Server) Info() (map[string]string, error) {
 	// 调用API获取信息
-	resp, err := callApi("GET", "/v1/api/info", nil)
+	resp, err := s.cachedApi("/v1/api/info")
 	if err != nil {
 		// 如果调用API出错，则返回错误
 		return nil, err
@@ -109,9 +110,9 @@ type ResponseMetrics struct {
 	Days             int     `json:"days"`
 }
 
-func Metrics() (map[string]interface{}, error) {
+func (s *Server) Metrics() (map[string]interface{}, error) {
 	// 调用API获取指标数据
-	resp, err := callApi("GET", "/v1/api/metrics", nil)
+	resp, err := s.cachedApi("/v1/api/metrics")
 	if err != nil {
 		// 如果调用API出错，则返回错误
 		return nil, err
@@ -159,9 +160,9 @@ type ResponsePlayers struct {
 	Players []ResponsePlayer `json:"players"`
 }
 
-func ShowPlayers() ([]database.OnlinePlayer, error) {
+func (s *Server) ShowPlayers() ([]database.OnlinePlayer, error) {
 	// 调用API获取玩家数据
-	resp, err := callApi("GET", "/v1/api/players", nil)
+	resp, err := s.cachedApi("/v1/api/players")
 	if err != nil {
 		return nil, err
 	}
@@ -233,7 +234,7 @@ type RequestUserId struct {
 	UserId string `json:"userid"`
 }
 
-func KickPlayer(steamId string) error {
+func (s *Server) KickPlayer(steamId string) error {
 	// 将steamId封装成json格式的数据
 	b, err := json.Marshal(RequestUserId{
 		UserId: steamId,
@@ -244,7 +245,7 @@ func KickPlayer(steamId string) error {
 	}
 
 	// 调用API接口
-	_, err = callApi("POST", "/v1/api/kick", b)
+	_, err = s.callApi("POST", "/v1/api/kick", b)
 	if err != nil {
 		// 如果出现错误，则返回错误
 		return err
@@ -253,7 +254,7 @@ func KickPlayer(steamId string) error {
 	return nil
 }
 
-func BanPlayer(steamId string) error {
+func (s *Server) BanPlayer(steamId string) error {
 	// 将steamId封装到RequestUserId结构体中，并序列化为JSON格式的字节切片
 	b, err := json.Marshal(RequestUserId{
 		UserId: steamId,
@@ -263,7 +264,7 @@ func BanPlayer(steamId string) error {
 		return err
 	}
 	// 调用callApi函数发送POST请求到"/v1/api/ban"接口，并传入序列化后的数据
-	_, err = callApi("POST", "/v1/api/ban", b)
+	_, err = s.callApi("POST", "/v1/api/ban", b)
 	if err != nil {
 		// 如果请求失败，则返回错误
 		return err
@@ -271,7 +272,7 @@ func BanPlayer(steamId string) error {
 	return nil
 }
 
-func UnBanPlayer(steamId string) error {
+func (s *Server) UnBanPlayer(steamId string) error {
 	// 将steamId封装到RequestUserId结构体中，并序列化为JSON格式
 	b, err := json.Marshal(RequestUserId{
 		UserId: steamId,
@@ -282,7 +283,7 @@ func UnBanPlayer(steamId string) error {
 	}
 
 	// 调用API进行POST请求，解禁玩家
-	_, err = callApi("POST", "/v1/api/unban", b)
+	_, err = s.callApi("POST", "/v1/api/unban", b)
 	if err != nil {
 		// 如果调用API失败，则返回错误
 		return err
@@ -295,7 +296,7 @@ type RequestBroadcast struct {
 	Message string `json:"message"`
 }
 
-func Broadcast(message string) error {
+func (s *Server) Broadcast(message string) error {
 	// 将RequestBroadcast结构体序列化为JSON格式的字节数组
 	b, err := json.Marshal(RequestBroadcast{
 		Message: message,
@@ -306,7 +307,7 @@ func Broadcast(message string) error {
 	}
 
 	// 调用API接口发送POST请求
-	_, err = callApi("POST", "/v1/api/announce", b)
+	_, err = s.callApi("POST", "/v1/api/announce", b)
 	if err != nil {
 		// 如果请求发送失败，则返回错误
 		return err
@@ -321,7 +322,7 @@ type RequestShutdown struct {
 	Message  string `json:"message"`
 }
 
-func Shutdown(seconds int, message string) error {
+func (s *Server) Shutdown(seconds int, message string) error {
 	// 将RequestShutdown结构体序列化为JSON格式
 	b, err := json.Marshal(RequestShutdown{
 		Waittime: seconds,
@@ -333,7 +334,7 @@ func Shutdown(seconds int, message string) error {
 	}
 
 	// 调用API接口，发送POST请求
-	_, err = callApi("POST", "/v1/api/shutdown", b)
+	_, err = s.callApi("POST", "/v1/api/shutdown", b)
 	if err != nil {
 		// 如果API调用出错，则返回错误
 		return err
@@ -343,12 +344,30 @@ func Shutdown(seconds int, message string) error {
 	return nil
 }
 
-func DoExit() error {
+// Save 让服务器立即保存世界存档
+func (s *Server) Save() error {
+	_, err := s.callApi("POST", "/v1/api/save", nil)
+	return err
+}
+
+func (s *Server) DoExit() error {
 	// 调用API，使用POST方法向"/v1/api/stop"路径发送请求，携带的数据为nil
-	_, err := callApi("POST", "/v1/api/stop", nil)
+	_, err := s.callApi("POST", "/v1/api/stop", nil)
 	if err != nil {
 		// 如果调用API时发生错误，则返回错误
 		return err
 	}
 	return nil
 }
+
+// WaitForShutdown 等待服务器关闭，即 REST API 无法访问为止
+func (s *Server) WaitForShutdown(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := s.Info(); err != nil {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return errors.New("等待服务器关闭超时")
+}
@@ -3,20 +3,21 @@ package tool
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"time"
 
-	"github.com/spf13/viper"
-	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/google/uuid"
 	"github.com/qycnet/palworld-server-tool-main/internal/database"
 	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/sav"
 	"github.com/qycnet/palworld-server-tool-main/internal/source"
+	"github.com/qycnet/palworld-server-tool-main/internal/storage"
 	"github.com/qycnet/palworld-server-tool-main/internal/system"
 	"github.com/qycnet/palworld-server-tool-main/service"
+	"github.com/spf13/viper"
 	"go.etcd.io/bbolt"
 )
 
@@ -25,130 +26,310 @@ type Sturcture struct {
 	Guilds  []database.Guild  `json:"guilds"`
 }
 
-func getSavCli() (string, error) {
-	savCliPath := viper.GetString("save.decode_path")
-	if savCliPath == "" || savCliPath == "/path/to/your/sav_cli" {
-		ed, err := system.GetExecDir()
-		if err != nil {
-			logger.Errorf("获取执行目录时出错sav_cli: %s", err)
-			return "", err
-		}
-		savCliPath = filepath.Join(ed, "sav_cli")
-		if runtime.GOOS == "windows" {
-			savCliPath += ".exe"
+func Decode(db *bbolt.DB, file string) error {
+	levelFilePath, err := getFromSource(file, "decode")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(filepath.Dir(levelFilePath))
+
+	players, guilds, err := sav.Decode(levelFilePath)
+	// 部分公会无法解析时只更新解析出的公会，不对比公会变化，否则会误将其记录为已解散
+	incomplete := errors.Is(err, sav.ErrIncompleteGuilds)
+	if err != nil && !incomplete {
+		return errors.New("解析存档时出错: " + err.Error())
+	}
+	data := Sturcture{Players: players, Guilds: guilds}
+
+	if err = service.PutPlayers(db, data.Players); err != nil {
+		return errors.New("保存玩家数据时出错: " + err.Error())
+	}
+	// 记录本次同步的玩家快照
+	if err = service.AddPlayerSnapshots(db, data.Players, time.Now()); err != nil {
+		return errors.New("保存玩家历史记录时出错: " + err.Error())
+	}
+	// 保留天数设置为0时永久保留玩家历史记录
+	if keepDays := viper.GetInt("task.player_history_keep_days"); keepDays > 0 {
+		if err = service.CleanPlayerHistory(db, time.Now().AddDate(0, 0, -keepDays)); err != nil {
+			logger.Errorf("清理玩家历史记录时出错: %v\n", err)
 		}
 	}
-	if _, err := os.Stat(savCliPath); err != nil {
-		return "", err
+	if incomplete {
+		logger.Warn("部分公会无法解析, 本次同步不记录公会变化\n")
+		err = service.PutGuilds(db, data.Guilds)
+	} else {
+		err = service.ReconcileGuilds(db, data.Guilds)
 	}
-	return savCliPath, nil
+	if err != nil {
+		return errors.New("保存公会数据时出错: " + err.Error())
+	}
+	logger.Infof("存档解析完成, 玩家 %d, 公会 %d\n", len(data.Players), len(data.Guilds))
+	return nil
 }
 
-func Decode(file string) error {
-	savCli, err := getSavCli()
+// Backup 备份当前存档并保存备份记录，每个文件按内容保存到存储目标，已存在的内容不再重复上传
+func (s *Server) Backup(db *bbolt.DB) (database.Backup, error) {
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+
+	sourcePath := s.Server.Save.Path
+
+	levelFilePath, err := getFromSource(sourcePath, "backup")
 	if err != nil {
-		return errors.New("获取可执行路径时出错: " + err.Error())
+		return database.Backup{}, err
 	}
+	defer os.RemoveAll(filepath.Dir(levelFilePath))
 
-	levelFilePath, err := getFromSource(file, "decode")
+	targets, err := s.StorageTargets()
 	if err != nil {
-		return err
+		return database.Backup{}, err
 	}
-	defer os.RemoveAll(filepath.Dir(levelFilePath))
 
-	baseUrl := fmt.Sprintf("http://127.0.0.1:%d", viper.GetInt("web.port"))
-	if viper.GetBool("web.tls") && !strings.HasSuffix(baseUrl, "/") {
-		baseUrl = viper.GetString("web.public_url")
+	savDir := filepath.Dir(levelFilePath)
+	files, err := hashDir(savDir)
+	if err != nil {
+		return database.Backup{}, fmt.Errorf("无法计算存档文件校验和: %s", err)
+	}
+	backups, err := service.ListBackups(db, time.Time{}, time.Time{})
+	if err != nil {
+		return database.Backup{}, fmt.Errorf("无法列出备份: %s", err)
 	}
 
-	requestUrl := fmt.Sprintf("%s/api/", baseUrl)
-	tokenString, err := auth.GenerateToken()
+	now := time.Now()
+	backup := database.Backup{
+		BackupId: uuid.New().String(),
+		SaveTime: now,
+		Path:     fmt.Sprintf("%s.zip", now.Format("2006-01-02-15-04-05")),
+		Targets:  make([]string, 0, len(targets)),
+		Files:    files,
+	}
+	for _, target := range targets {
+		referenced := referencedObjects(backups, target.Name, "")
+		if err = putObjects(target, savDir, files, referenced); err != nil {
+			logger.Errorf("无法上传备份到 %s: %s\n", target.Name, err)
+			continue
+		}
+		backup.Targets = append(backup.Targets, target.Name)
+	}
+	if len(backup.Targets) == 0 {
+		return database.Backup{}, errors.New("备份未能保存到任何存储目标")
+	}
+	if err = service.AddBackup(db, backup); err != nil {
+		return database.Backup{}, err
+	}
+	return backup, nil
+}
+
+func putFile(target storage.Target, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
 	if err != nil {
-		return errors.New("生成令牌时出错: " + err.Error())
+		return err
 	}
-	execArgs := []string{"-f", levelFilePath, "--request", requestUrl, "--token", tokenString}
-	cmd := exec.Command(savCli, execArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Start()
+	return target.Put(name, f, info.Size())
+}
+
+// backupTargets 返回保存了该备份且仍在配置中的存储目标
+func (s *Server) backupTargets(backup database.Backup) ([]storage.Target, error) {
+	targets, err := s.StorageTargets()
 	if err != nil {
-		return errors.New("启动命令时出错: " + err.Error())
+		return nil, err
+	}
+	names := targetNames(backup)
+	result := make([]storage.Target, 0, len(names))
+	for _, name := range names {
+		for _, target := range targets {
+			if target.Name == name {
+				result = append(result, target)
+			}
+		}
 	}
-	err = cmd.Wait()
+	return result, nil
+}
+
+// OpenBackup 读取备份的完整 zip，增量备份会根据清单从存储目标中重新组装
+func (s *Server) OpenBackup(backup database.Backup) (io.ReadCloser, error) {
+	targets, err := s.backupTargets(backup)
 	if err != nil {
-		return errors.New("等待命令时出错: " + err.Error())
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("保存该备份的存储目标均已不在配置中")
+	}
+	// 旧版本的完整 zip 备份
+	if len(backup.Files) == 0 {
+		return getObject(targets, backup.Path)
 	}
 
-	return nil
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeZip(pw, targets, backup))
+	}()
+	return pr, nil
 }
 
-func Backup() (string, error) {
-	sourcePath := viper.GetString("save.path")
+// DeleteBackup 从所有存储目标中删除不再被引用的文件，全部删除后再删除备份记录。
+// 部分存储目标删除失败时，记录中只保留这些目标，可以再次删除
+func (s *Server) DeleteBackup(db *bbolt.DB, backup database.Backup) error {
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
 
-	levelFilePath, err := getFromSource(sourcePath, "backup")
+	backup, err := service.GetBackup(db, backup.BackupId)
 	if err != nil {
-		return "", err
+		return err
 	}
-	defer os.RemoveAll(filepath.Dir(levelFilePath))
+	targets, err := s.backupTargets(backup)
+	if err != nil {
+		return err
+	}
+	backups, err := service.ListBackups(db, time.Time{}, time.Time{})
+	if err != nil {
+		return fmt.Errorf("无法列出备份: %s", err)
+	}
+
+	failed := make([]string, 0)
+	errs := make([]string, 0)
+	for _, target := range targets {
+		if err := deleteFromTarget(target, backup, backups); err != nil {
+			failed = append(failed, target.Name)
+			errs = append(errs, fmt.Sprintf("%s: %s", target.Name, err))
+		}
+	}
+	if len(failed) == 0 {
+		return service.DeleteBackup(db, backup.BackupId)
+	}
+
+	backup.Targets = failed
+	if err = service.AddBackup(db, backup); err != nil {
+		logger.Errorf("无法更新数据库中的备份记录: %s\n", err)
+	}
+	return fmt.Errorf("无法删除备份: %s", strings.Join(errs, "; "))
+}
 
-	backupDir, err := GetBackupDir()
+// Restore 用备份中的存档替换 save.path 对应的存档目录，调用前需确保服务器已关闭
+func (s *Server) Restore(backup database.Backup) error {
+	tempDir, err := os.MkdirTemp("", "palworldsav-restore-")
 	if err != nil {
-		return "", fmt.Errorf("无法获取备份目录: %s", err)
+		return err
 	}
+	defer os.RemoveAll(tempDir)
 
-	currentTime := time.Now().Format("2006-01-02-15-04-05")
-	backupZipFile := filepath.Join(backupDir, fmt.Sprintf("%s.zip", currentTime))
-	err = system.ZipDir(filepath.Dir(levelFilePath), backupZipFile)
+	r, err := s.OpenBackup(backup)
+	if err != nil {
+		return fmt.Errorf("无法读取备份文件: %s", err)
+	}
+	backupZipFile := filepath.Join(tempDir, backup.Path)
+	err = saveToFile(r, backupZipFile)
+	r.Close()
 	if err != nil {
-		return "", fmt.Errorf("无法创建备份 zip: %s", err)
+		return fmt.Errorf("无法读取备份文件: %s", err)
 	}
-	return filepath.Base(backupZipFile), nil
+
+	savDir := filepath.Join(tempDir, "sav")
+	if err = system.UnzipDir(backupZipFile, savDir); err != nil {
+		return fmt.Errorf("无法解压备份文件: %s", err)
+	}
+	if _, err = os.Stat(filepath.Join(savDir, "Level.sav")); err != nil {
+		return errors.New("备份文件中找不到 Level.sav")
+	}
+	return putToSource(s.Server.Save.Path, savDir)
 }
 
-func GetBackupDir() (string, error) {
-	wd, err := os.Getwd()
+func saveToFile(r io.Reader, file string) error {
+	f, err := os.Create(file)
 	if err != nil {
-		return "", err
+		return err
 	}
-	backDir := filepath.Join(wd, "backups")
-	if err = system.CheckAndCreateDir(backDir); err != nil {
-		return "", err
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
 	}
-	return backDir, nil
+	return f.Close()
 }
 
-func CleanOldBackups(db *bbolt.DB, keepDays int) error {
-	backupDir, err := GetBackupDir()
+// CleanOldBackups 按各存储目标的保留天数删除旧备份，备份从所有目标中删除后移除记录
+func (s *Server) CleanOldBackups(db *bbolt.DB) error {
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+
+	targets, err := s.StorageTargets()
 	if err != nil {
-		return fmt.Errorf("无法获取备份目录: %s", err)
+		return err
 	}
 
-	deadline := time.Now().AddDate(0, 0, -keepDays)
-
-	backups, err := service.ListBackups(db, time.Time{}, time.Now())
+	backups, err := service.ListBackups(db, time.Time{}, time.Time{})
 	if err != nil {
 		return fmt.Errorf("无法列出备份: %s", err)
 	}
 
-	for _, backup := range backups {
-		if backup.SaveTime.Before(deadline) {
-			err = os.Remove(filepath.Join(backupDir, backup.Path))
-			if err != nil {
-				if !os.IsNotExist(err) {
-					logger.Errorf("无法删除旧的备份文件 %s: %s", backup.Path, err)
+	for i := range backups {
+		backup := &backups[i]
+		names := targetNames(*backup)
+		remain := make([]string, 0, len(names))
+		for _, name := range names {
+			var target *storage.Target
+			for i := range targets {
+				if targets[i].Name == name {
+					target = &targets[i]
 				}
 			}
+			// 已从配置中移除的目标无法清理，保留记录
+			if target == nil || target.KeepDays <= 0 || backup.SaveTime.After(time.Now().AddDate(0, 0, -target.KeepDays)) {
+				remain = append(remain, name)
+				continue
+			}
+			if err = deleteFromTarget(*target, *backup, backups); err != nil {
+				logger.Errorf("无法从 %s 删除旧的备份文件 %s: %s\n", name, backup.Path, err)
+				remain = append(remain, name)
+			}
+		}
+		if len(remain) == len(names) {
+			continue
+		}
 
+		// 同步更新内存中的记录，后续判断对象引用时不再计入已删除的目标
+		backup.Targets = remain
+		if len(remain) == 0 {
 			err = service.DeleteBackup(db, backup.BackupId)
-			if err != nil {
-				logger.Errorf("无法从数据库中删除备份记录: %s", err)
-			}
+		} else {
+			err = service.AddBackup(db, *backup)
+		}
+		if err != nil {
+			logger.Errorf("无法更新数据库中的备份记录: %s\n", err)
 		}
 	}
 
 	return nil
 }
 
+// CheckSave 检查能否从 save.path 读取存档，与同步存档时的读取方式相同，并检查存档格式是否受支持
+func (s *Server) CheckSave() error {
+	if s.Server.Save.Path == "" {
+		return errors.New("save.path 未设置")
+	}
+	levelFilePath, err := getFromSource(s.Server.Save.Path, "check")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(filepath.Dir(levelFilePath))
+	f, err := os.Open(levelFilePath)
+	if err != nil {
+		return errors.New("存档中没有 Level.sav")
+	}
+	defer f.Close()
+	// 只读取头部，提前发现无法解析的存档格式(例如 Oodle 压缩的存档)
+	header := make([]byte, 24)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("无法读取 Level.sav: %v", err)
+	}
+	return sav.CheckFormat(header[:n])
+}
+
 func getFromSource(file, way string) (string, error) {
 	var levelFilePath string
 	var err error
@@ -187,4 +368,35 @@ func getFromSource(file, way string) (string, error) {
 		}
 	}
 	return levelFilePath, nil
-}
\ No newline at end of file
+}
+
+// putToSource 用 srcDir 中的存档文件替换 file 对应的存档目录
+func putToSource(file, srcDir string) error {
+	if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+		return errors.New("不支持恢复存档到 http(s) 地址")
+	} else if strings.HasPrefix(file, "k8s://") {
+		// k8s://namespace/pod/container:remotePath
+		namespace, podName, container, remotePath, err := source.ParseK8sAddress(file)
+		if err != nil {
+			return errors.New("解析 K8s 地址时出错: " + err.Error())
+		}
+		if err = source.CopyToPod(namespace, podName, container, remotePath, srcDir); err != nil {
+			return errors.New("复制文件到 Pod 时出错: " + err.Error())
+		}
+	} else if strings.HasPrefix(file, "docker://") {
+		// docker://containerID(Name):remotePath
+		containerId, remotePath, err := source.ParseDockerAddress(file)
+		if err != nil {
+			return errors.New("解析 docker 地址时出错: " + err.Error())
+		}
+		if err = source.CopyToContainer(containerId, remotePath, srcDir); err != nil {
+			return errors.New("复制文件到容器时出错: " + err.Error())
+		}
+	} else {
+		// local file
+		if err := source.CopyToLocal(srcDir, file); err != nil {
+			return errors.New("将文件写回存档目录时出错: " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package tool
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/storage"
+	"github.com/spf13/viper"
+	"go.etcd.io/bbolt"
+)
+
+// DefaultServerId 默认服务器的 ID，对应顶层的 rcon、rest、save 配置
+const DefaultServerId = "default"
+
+var ErrNoServer = errors.New("服务器不存在")
+
+// 每次请求都会读取 servers 配置，无效的服务器 ID 只提示一次
+var invalidServerIds sync.Map
+
+// Server 一个受管理的游戏服务器，Save 方法与存档配置同名，访问存档配置时使用 s.Server.Save
+type Server struct {
+	config.Server
+}
+
+// DefaultServer 根据顶层的 rcon、rest、save 配置返回默认服务器
+func DefaultServer() *Server {
+	return &Server{config.Server{
+		Id: DefaultServerId,
+		Rcon: config.Rcon{
+			Address:   viper.GetString("rcon.address"),
+			Password:  viper.GetString("rcon.password"),
+			UseBase64: viper.GetBool("rcon.use_base64"),
+			Timeout:   viper.GetInt("rcon.timeout"),
+		},
+		Rest: config.Rest{
+			Address:  viper.GetString("rest.address"),
+			Username: viper.GetString("rest.username"),
+			Password: viper.GetString("rest.password"),
+			Timeout:  viper.GetInt("rest.timeout"),
+			CacheTtl: viper.GetInt("rest.cache_ttl"),
+		},
+		Save: config.Save{
+			Path:                 viper.GetString("save.path"),
+			SyncInterval:         viper.GetInt("save.sync_interval"),
+			BackupInterval:       viper.GetInt("save.backup_interval"),
+			BackupKeepDays:       viper.GetInt("save.backup_keep_days"),
+			BackupVerifyInterval: viper.GetInt("save.backup_verify_interval"),
+		},
+		RestartCron: viper.GetString("task.restart_cron"),
+		Supervisor: config.Supervisor{
+			Enabled:     viper.GetBool("supervisor.enabled"),
+			Path:        viper.GetString("supervisor.path"),
+			Args:        viper.GetStringSlice("supervisor.args"),
+			WorkDir:     viper.GetString("supervisor.work_dir"),
+			StopTimeout: viper.GetInt("supervisor.stop_timeout"),
+		},
+	}}
+}
+
+// serverOverrides 用于区分 servers 中未设置和设置为 0 的配置项，设置为 0 时禁用对应的任务
+type serverOverrides struct {
+	Rest struct {
+		CacheTtl *int `mapstructure:"cache_ttl"`
+	} `mapstructure:"rest"`
+	Save struct {
+		SyncInterval         *int `mapstructure:"sync_interval"`
+		BackupInterval       *int `mapstructure:"backup_interval"`
+		BackupKeepDays       *int `mapstructure:"backup_keep_days"`
+		BackupVerifyInterval *int `mapstructure:"backup_verify_interval"`
+	} `mapstructure:"save"`
+}
+
+// orDefault 返回 servers 中设置的值，未设置时返回默认服务器的值
+func orDefault(value *int, def int) int {
+	if value == nil {
+		return def
+	}
+	return *value
+}
+
+// ExtraServers 返回 servers 中配置的额外服务器，未设置的超时和间隔使用顶层配置
+func ExtraServers() []*Server {
+	var configs []config.Server
+	var overrides []serverOverrides
+	if err := viper.UnmarshalKey("servers", &configs); err != nil {
+		logger.Errorf("无法解析 servers 配置: %v\n", err)
+		return nil
+	}
+	if err := viper.UnmarshalKey("servers", &overrides); err != nil || len(overrides) != len(configs) {
+		overrides = make([]serverOverrides, len(configs))
+	}
+	def := DefaultServer()
+	servers := make([]*Server, 0, len(configs))
+	ids := make(map[string]bool, len(configs))
+	for i, cfg := range configs {
+		if !config.ServerIdPattern.MatchString(cfg.Id) || cfg.Id == DefaultServerId || ids[cfg.Id] {
+			if _, warned := invalidServerIds.LoadOrStore(cfg.Id, true); !warned {
+				logger.Errorf("服务器 ID %q 无效或重复, 已忽略\n", cfg.Id)
+			}
+			continue
+		}
+		ids[cfg.Id] = true
+		if cfg.Name == "" {
+			cfg.Name = cfg.Id
+		}
+		if cfg.Rest.Username == "" {
+			cfg.Rest.Username = def.Rest.Username
+		}
+		if cfg.Rest.Timeout == 0 {
+			cfg.Rest.Timeout = def.Rest.Timeout
+		}
+		cfg.Rest.CacheTtl = orDefault(overrides[i].Rest.CacheTtl, def.Rest.CacheTtl)
+		if cfg.Rcon.Timeout == 0 {
+			cfg.Rcon.Timeout = def.Rcon.Timeout
+		}
+		if cfg.Supervisor.StopTimeout == 0 {
+			cfg.Supervisor.StopTimeout = def.Supervisor.StopTimeout
+		}
+		save := overrides[i].Save
+		cfg.Save.SyncInterval = orDefault(save.SyncInterval, def.Server.Save.SyncInterval)
+		cfg.Save.BackupInterval = orDefault(save.BackupInterval, def.Server.Save.BackupInterval)
+		cfg.Save.BackupKeepDays = orDefault(save.BackupKeepDays, def.Server.Save.BackupKeepDays)
+		cfg.Save.BackupVerifyInterval = orDefault(save.BackupVerifyInterval, def.Server.Save.BackupVerifyInterval)
+		servers = append(servers, &Server{cfg})
+	}
+	return servers
+}
+
+// Servers 返回所有服务器，第一个为默认服务器
+func Servers() []*Server {
+	return append([]*Server{DefaultServer()}, ExtraServers()...)
+}
+
+func GetServer(id string) (*Server, error) {
+	for _, server := range Servers() {
+		if server.Id == id {
+			return server, nil
+		}
+	}
+	return nil, ErrNoServer
+}
+
+func (s *Server) IsDefault() bool {
+	return s.Id == DefaultServerId
+}
+
+// DB 返回服务器的数据库，每个服务器的玩家、公会、白名单、备份等数据相互隔离
+func (s *Server) DB() *bbolt.DB {
+	if s.IsDefault() {
+		return database.GetDB()
+	}
+	return database.GetServerDB(s.Id)
+}
+
+// StorageTargets 返回服务器的备份存储目标。额外服务器未设置 save.backup_targets 时
+// 与默认服务器共用存储目标，备份保存在以服务器 ID 命名的目录下
+func (s *Server) StorageTargets() ([]storage.Target, error) {
+	configs := s.Server.Save.BackupTargets
+	shared := len(configs) == 0
+	if shared {
+		var err error
+		if configs, err = storage.Configs(); err != nil {
+			return nil, err
+		}
+	}
+	targets, err := storage.NewTargets(configs, s.Server.Save.BackupKeepDays)
+	if err != nil || s.IsDefault() || !shared {
+		return targets, err
+	}
+	for i := range targets {
+		targets[i] = storage.WithPrefix(targets[i], "servers/"+s.Id+"/")
+	}
+	return targets, nil
+}
+
+// 以下函数操作默认服务器
+
+func Info() (map[string]string, error) {
+	return DefaultServer().Info()
+}
+
+func Metrics() (map[string]interface{}, error) {
+	return DefaultServer().Metrics()
+}
+
+func ShowPlayers() ([]database.OnlinePlayer, error) {
+	return DefaultServer().ShowPlayers()
+}
+
+func KickPlayer(steamId string) error {
+	return DefaultServer().KickPlayer(steamId)
+}
+
+func BanPlayer(steamId string) error {
+	return DefaultServer().BanPlayer(steamId)
+}
+
+func UnBanPlayer(steamId string) error {
+	return DefaultServer().UnBanPlayer(steamId)
+}
+
+func Broadcast(message string) error {
+	return DefaultServer().Broadcast(message)
+}
+
+func Shutdown(seconds int, message string) error {
+	return DefaultServer().Shutdown(seconds, message)
+}
+
+func Save() error {
+	return DefaultServer().Save()
+}
+
+func DoExit() error {
+	return DefaultServer().DoExit()
+}
+
+func WaitForShutdown(timeout time.Duration) error {
+	return DefaultServer().WaitForShutdown(timeout)
+}
+
+func CustomCommand(command string) (string, error) {
+	return DefaultServer().CustomCommand(command)
+}
+
+func Backup(db *bbolt.DB) (database.Backup, error) {
+	return DefaultServer().Backup(db)
+}
+
+func OpenBackup(backup database.Backup) (io.ReadCloser, error) {
+	return DefaultServer().OpenBackup(backup)
+}
+
+func DeleteBackup(db *bbolt.DB, backup database.Backup) error {
+	return DefaultServer().DeleteBackup(db, backup)
+}
+
+func Restore(backup database.Backup) error {
+	return DefaultServer().Restore(backup)
+}
+
+func CleanOldBackups(db *bbolt.DB) error {
+	return DefaultServer().CleanOldBackups(db)
+}
+
+func VerifyBackup(db *bbolt.DB, backupId string) (database.Backup, error) {
+	return DefaultServer().VerifyBackup(db, backupId)
+}
+
+func VerifyBackups(db *bbolt.DB) (int, error) {
+	return DefaultServer().VerifyBackups(db)
+}
@@ -0,0 +1,201 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/qycnet/palworld-server-tool-main/internal/config"
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"github.com/qycnet/palworld-server-tool-main/internal/event"
+	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/service"
+	"github.com/spf13/viper"
+)
+
+const (
+	EventPlayerJoin         = "player_join"
+	EventPlayerLeave        = "player_leave"
+	EventPlayerKick         = "player_kick"
+	EventPlayerBan          = "player_ban"
+	EventPlayerUnban        = "player_unban"
+	EventWhitelistKick      = "whitelist_kick"
+	EventBackupSuccess      = "backup_success"
+	EventBackupFailure      = "backup_failure"
+	EventSavSyncFailure     = "sav_sync_failure"
+	EventServerUnreachable  = "server_unreachable"
+	EventServerRecovered    = "server_recovered"
+	EventServerRestart      = "server_restart"
+	EventServerCrash        = "server_crash"
+	EventServerFpsLow       = "server_fps_low"
+	EventServerFpsRecovered = "server_fps_recovered"
+	EventTest               = "test"
+)
+
+// 默认重试次数，以及第一次重试前的等待时间，之后每次翻倍
+const (
+	defaultRetries = 3
+	retryBackoff   = 2 * time.Second
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// 匿名用户也能从事件流中接收的事件，其余事件需要登录
+var publicEvents = map[string]bool{
+	EventPlayerJoin:         true,
+	EventPlayerLeave:        true,
+	EventServerUnreachable:  true,
+	EventServerRecovered:    true,
+	EventServerRestart:      true,
+	EventServerCrash:        true,
+	EventServerFpsLow:       true,
+	EventServerFpsRecovered: true,
+}
+
+type Event struct {
+	Type    string                 `json:"event"`
+	Time    time.Time              `json:"time"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Webhooks 返回配置的所有 webhook
+func Webhooks() []config.Webhook {
+	var hooks []config.Webhook
+	if err := viper.UnmarshalKey("webhooks", &hooks); err != nil {
+		logger.Errorf("无法解析 webhooks 配置: %v\n", err)
+		return nil
+	}
+	// 地址中通常带有令牌，不能作为名称出现在投递记录和接口中
+	for i := range hooks {
+		if hooks[i].Name == "" {
+			hooks[i].Name = fmt.Sprintf("webhook-%d", i)
+		}
+	}
+	return hooks
+}
+
+func subscribes(hook config.Webhook, eventType string) bool {
+	if len(hook.Events) == 0 || eventType == EventTest {
+		return true
+	}
+	for _, e := range hook.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Emit 异步通知所有订阅了该事件的 webhook
+func Emit(eventType, message string, data map[string]interface{}) {
+	event := Event{
+		Type:    eventType,
+		Time:    time.Now(),
+		Message: message,
+		Data:    data,
+	}
+	publish(event)
+	for _, hook := range Webhooks() {
+		if !subscribes(hook, eventType) {
+			continue
+		}
+		go Send(hook, event)
+	}
+}
+
+// publish 将事件同时推送到实时事件流
+func publish(e Event) {
+	stream := event.Event{
+		Type:    e.Type,
+		Time:    e.Time,
+		Message: e.Message,
+	}
+	// 避免 nil map 被编码为 null
+	if e.Data != nil {
+		stream.Data = e.Data
+	}
+	if !publicEvents[e.Type] {
+		stream.Role = auth.RoleViewer
+	}
+	event.Publish(stream)
+}
+
+// Send 发送事件到 webhook，失败时按指数退避重试，并记录投递结果
+func Send(hook config.Webhook, event Event) database.WebhookDelivery {
+	delivery := database.WebhookDelivery{
+		Time:    event.Time,
+		Webhook: hook.Name,
+		Event:   event.Type,
+	}
+
+	body, err := payload(hook.Format, event)
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		retries := hook.Retries
+		if retries <= 0 {
+			retries = defaultRetries
+		}
+		backoff := retryBackoff
+		for delivery.Attempts = 1; ; delivery.Attempts++ {
+			var retry bool
+			delivery.Status, retry, err = post(hook.Url, body)
+			if err == nil {
+				delivery.Success = true
+				delivery.Error = ""
+				break
+			}
+			delivery.Error = err.Error()
+			if !retry || delivery.Attempts > retries {
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	if !delivery.Success {
+		logger.Warnf("webhook %s 发送 %s 失败: %s\n", hook.Name, event.Type, delivery.Error)
+	}
+	if err := service.AddWebhookDelivery(database.GetDB(), delivery); err != nil {
+		logger.Errorf("无法保存 webhook 投递记录: %v\n", err)
+	}
+	return delivery
+}
+
+// post 发送请求，返回状态码以及失败时是否需要重试
+func post(url string, body []byte) (int, bool, error) {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	// 只有服务端错误和限流需要重试
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return resp.StatusCode, retry, fmt.Errorf("%s %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+// payload 按 webhook 的格式生成请求体
+func payload(format string, event Event) ([]byte, error) {
+	text := fmt.Sprintf("[%s] %s", event.Type, event.Message)
+	switch format {
+	case "", "json":
+		return json.Marshal(event)
+	case "discord":
+		return json.Marshal(map[string]interface{}{"content": text})
+	case "slack":
+		return json.Marshal(map[string]interface{}{"text": text})
+	default:
+		return nil, errors.New("不支持的 webhook 格式: " + format)
+	}
+}
@@ -14,9 +14,12 @@ import (
 	"github.com/spf13/viper"
 	"github.com/qycnet/palworld-server-tool-main/api"
 	"github.com/qycnet/palworld-server-tool-main/docs"
+	"github.com/qycnet/palworld-server-tool-main/internal/auth"
+	"github.com/qycnet/palworld-server-tool-main/internal/check"
 	"github.com/qycnet/palworld-server-tool-main/internal/config"
 	"github.com/qycnet/palworld-server-tool-main/internal/database"
 	"github.com/qycnet/palworld-server-tool-main/internal/logger"
+	"github.com/qycnet/palworld-server-tool-main/internal/supervisor"
 	"github.com/qycnet/palworld-server-tool-main/internal/system"
 	"github.com/qycnet/palworld-server-tool-main/internal/task"
 )
@@ -44,6 +47,20 @@ func setupFlags() {
 	flag.Parse()
 }
 
+// checkConfig 校验配置并检查 REST API、RCON、存档来源和备份存储，有检查项失败时以状态码 1 退出
+func checkConfig(args []string) {
+	flags := flag.NewFlagSet("check-config", flag.ExitOnError)
+	flags.StringVar(&cfgFile, "config", "", "config file")
+	flags.Parse(args)
+
+	config.Init(cfgFile, &conf)
+	results := check.Run(&conf, check.Options{Save: true, Storage: true})
+	check.Print(os.Stdout, results)
+	if check.Failed(results) {
+		os.Exit(1)
+	}
+}
+
 //	@SecurityDefinitions.apikey	ApiKeyAuth
 //	@in							header
 //	@name						Authorization
@@ -51,11 +68,35 @@ func setupFlags() {
 // @license.name	Apache 2.0
 // @license.url	http://www.apache.org/licenses/LICENSE-2.0.html
 func main() {
-	db := database.GetDB()
-	defer db.Close()
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		checkConfig(os.Args[2:])
+		return
+	}
 
 	setupFlags()
 	config.Init(cfgFile, &conf)
+	if err := config.Validate(&conf); err != nil {
+		for _, result := range check.Config(&conf) {
+			logger.Errorf("%s\n", result.Message)
+		}
+		logger.Error("配置无效, 可以使用 pst check-config 检查配置和连接\n")
+		os.Exit(1)
+	}
+
+	db := database.GetDB()
+	defer db.Close()
+
+	auth.SetSecret(viper.GetString("web.password"))
+	// 配置文件修改后更新签名密钥，并在间隔变化时重新创建定时任务
+	config.OnReload(func(conf *config.Config) {
+		auth.SetSecret(conf.Web.Password)
+		task.Reschedule(db)
+	})
+	config.Watch()
+	// 启动时在后台检查连接，不阻塞启动。存档会在启动后立即同步，备份存储在备份时才会写入，这里都不检查
+	go func() {
+		check.Log(check.Run(&conf, check.Options{}))
+	}()
 
 	docs.SwaggerInfo.Title = "Palworld Manage API"
 	docs.SwaggerInfo.Version = version
@@ -65,6 +106,10 @@ func main() {
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// 默认不信任任何代理，避免客户端伪造 X-Forwarded-For 绕过限流或伪造审计日志中的 IP
+	if err := router.SetTrustedProxies(viper.GetStringSlice("web.trusted_proxies")); err != nil {
+		logger.Panicf("web.trusted_proxies 无效: %v\n", err)
+	}
 	router.Use(func(c *gin.Context) {
 		c.Set("version", version)
 		c.Next()
@@ -100,6 +145,10 @@ func main() {
 	go task.Schedule(db)
 	defer task.Shutdown()
 
+	// 启动启用了进程守护的服务器，退出时一并关闭
+	supervisor.StartAll()
+	defer supervisor.StopAll()
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
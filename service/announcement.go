@@ -0,0 +1,101 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+func AddAnnouncement(db *bbolt.DB, announcement database.Announcement) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为 "announcements" 的 bucket
+		b := tx.Bucket([]byte("announcements"))
+		v, err := json.Marshal(announcement)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(announcement.Id), v)
+	})
+}
+
+func PutAnnouncement(db *bbolt.DB, announcement database.Announcement) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("announcements"))
+		if b.Get([]byte(announcement.Id)) == nil {
+			return ErrNoRecord
+		}
+		v, err := json.Marshal(announcement)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(announcement.Id), v)
+	})
+}
+
+// SetAnnouncementLastRun 只更新最后一次执行的时间，避免覆盖同时进行的修改
+func SetAnnouncementLastRun(db *bbolt.DB, id string, lastRun time.Time) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("announcements"))
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrNoRecord
+		}
+		var announcement database.Announcement
+		if err := json.Unmarshal(v, &announcement); err != nil {
+			return err
+		}
+		announcement.LastRun = lastRun
+		v, err := json.Marshal(announcement)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), v)
+	})
+}
+
+func GetAnnouncement(db *bbolt.DB, id string) (database.Announcement, error) {
+	var announcement database.Announcement
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("announcements"))
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrNoRecord
+		}
+		return json.Unmarshal(v, &announcement)
+	})
+	if err != nil {
+		return database.Announcement{}, err
+	}
+	return announcement, nil
+}
+
+func ListAnnouncements(db *bbolt.DB) ([]database.Announcement, error) {
+	announcements := make([]database.Announcement, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("announcements"))
+		return b.ForEach(func(k, v []byte) error {
+			var announcement database.Announcement
+			if err := json.Unmarshal(v, &announcement); err != nil {
+				return err
+			}
+			announcements = append(announcements, announcement)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return announcements, nil
+}
+
+func DeleteAnnouncement(db *bbolt.DB, id string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("announcements"))
+		if b.Get([]byte(id)) == nil {
+			return ErrNoRecord
+		}
+		return b.Delete([]byte(id))
+	})
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+type AuditFilter struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Actor     string
+	Action    string
+	Target    string
+	// 操作的服务器 ID，默认服务器为 "default"，为空时不筛选
+	Server string
+	// 跳过的条数和返回的最大条数，Limit 为 0 时返回全部
+	Offset int
+	Limit  int
+}
+
+func (f AuditFilter) match(log database.AuditLog) bool {
+	if !f.StartTime.IsZero() && log.Time.Before(f.StartTime) {
+		return false
+	}
+	if !f.EndTime.IsZero() && log.Time.After(f.EndTime) {
+		return false
+	}
+	if f.Actor != "" && log.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && log.Action != f.Action {
+		return false
+	}
+	if f.Target != "" && log.Target != f.Target {
+		return false
+	}
+	if f.Server != "" && auditServer(log) != f.Server {
+		return false
+	}
+	return true
+}
+
+// 默认服务器的 ID，与 tool.DefaultServerId 相同，默认服务器的审计日志中不记录服务器 ID
+const auditDefaultServer = "default"
+
+// auditServer 返回审计日志的服务器 ID
+func auditServer(log database.AuditLog) string {
+	if log.Server == "" {
+		return auditDefaultServer
+	}
+	return log.Server
+}
+
+func AddAuditLog(db *bbolt.DB, log database.AuditLog) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为 "audit" 的 bucket
+		b := tx.Bucket([]byte("audit"))
+		// 使用自增序号作为键，保证按写入顺序排列
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(log)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+		return b.Put(key, v)
+	})
+}
+
+// ListAuditLogs 返回符合条件的审计日志，按时间倒序排列，同时返回分页前符合条件的总数
+func ListAuditLogs(db *bbolt.DB, filter AuditFilter) ([]database.AuditLog, int, error) {
+	logs := make([]database.AuditLog, 0)
+	total := 0
+	err := db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte("audit")).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var log database.AuditLog
+			if err := json.Unmarshal(v, &log); err != nil {
+				return err
+			}
+			if !filter.match(log) {
+				continue
+			}
+			if total >= filter.Offset && (filter.Limit <= 0 || len(logs) < filter.Limit) {
+				logs = append(logs, log)
+			}
+			total++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// CleanAuditLogs 删除 before 之前的审计日志，日志按写入顺序排列，遇到更新的日志时停止
+func CleanAuditLogs(db *bbolt.DB, before time.Time) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte("audit")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.First() {
+			var log database.AuditLog
+			if err := json.Unmarshal(v, &log); err != nil {
+				return err
+			}
+			if !log.Time.Before(before) {
+				return nil
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+func PutBan(db *bbolt.DB, ban database.Ban) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为 "bans" 的 bucket
+		b := tx.Bucket([]byte("bans"))
+		v, err := json.Marshal(ban)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(ban.PlayerUid), v)
+	})
+}
+
+func GetBan(db *bbolt.DB, playerUid string) (database.Ban, error) {
+	var ban database.Ban
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("bans"))
+		v := b.Get([]byte(playerUid))
+		if v == nil {
+			return ErrNoRecord
+		}
+		return json.Unmarshal(v, &ban)
+	})
+	if err != nil {
+		return database.Ban{}, err
+	}
+	return ban, nil
+}
+
+func DeleteBan(db *bbolt.DB, playerUid string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("bans"))
+		return b.Delete([]byte(playerUid))
+	})
+}
+
+// ListBans 返回全部封禁记录，按封禁时间倒序排列
+func ListBans(db *bbolt.DB) ([]database.Ban, error) {
+	bans := make([]database.Ban, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("bans"))
+		return b.ForEach(func(k, v []byte) error {
+			var ban database.Ban
+			if err := json.Unmarshal(v, &ban); err != nil {
+				return err
+			}
+			bans = append(bans, ban)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].CreatedAt.After(bans[j].CreatedAt)
+	})
+	return bans, nil
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"time"
 
 	"github.com/qycnet/palworld-server-tool-main/internal/database"
 	"go.etcd.io/bbolt"
@@ -31,6 +33,166 @@ func PutGuilds(db *bbolt.DB, guilds []database.Guild) error {
 	})
 }
 
+// ReconcileGuilds 保存存档中完整的公会列表，与已保存的公会对比：已解散的公会移到归档中，
+// 公会的创建、解散、成员变化和更换管理员记录到公会历史中。guilds 必须是存档中的所有公会
+func ReconcileGuilds(db *bbolt.DB, guilds []database.Guild) error {
+	now := time.Now()
+	return db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为"guilds"的bucket
+		b := tx.Bucket([]byte("guilds"))
+		stored := make(map[string]database.Guild)
+		groups := make(map[string]string)
+		if err := b.ForEach(func(k, v []byte) error {
+			var g database.Guild
+			if err := json.Unmarshal(v, &g); err != nil {
+				return err
+			}
+			stored[string(k)] = g
+			if g.GroupId != "" {
+				groups[g.GroupId] = string(k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		// 首次同步时没有可以对比的数据，不记录创建事件
+		initial := len(stored) == 0
+
+		events := make([]database.GuildEvent, 0)
+		event := func(eventType string, g database.Guild, player *database.GuildPlayer) {
+			e := database.GuildEvent{
+				Type:           eventType,
+				GroupId:        g.GroupId,
+				AdminPlayerUid: g.AdminPlayerUid,
+				GuildName:      g.Name,
+				Time:           now,
+			}
+			if player != nil {
+				e.PlayerUid = player.PlayerUid
+				e.Nickname = player.Nickname
+			}
+			events = append(events, e)
+		}
+
+		matched := make(map[string]bool, len(guilds))
+		// 更换了管理员的公会，原来的键需要删除
+		moved := make([]string, 0)
+		for _, g := range guilds {
+			key := g.AdminPlayerUid
+			old, ok := stored[key]
+			// 管理员相同但 GroupId 不同时，原公会已解散，管理员创建了新的公会
+			if ok && old.GroupId != "" && g.GroupId != "" && old.GroupId != g.GroupId {
+				ok = false
+			}
+			// 更换管理员后键会变化，按 GroupId 找到原来的公会
+			if !ok && g.GroupId != "" {
+				if k, found := groups[g.GroupId]; found && !matched[k] {
+					key, old, ok = k, stored[k], true
+					moved = append(moved, k)
+					event(database.GuildEventAdminChange, g, adminPlayer(g))
+				}
+			}
+			if !ok {
+				if !initial {
+					event(database.GuildEventCreate, g, nil)
+				}
+				continue
+			}
+			matched[key] = true
+			for _, player := range diffGuildPlayers(g.Players, old.Players) {
+				event(database.GuildEventJoin, g, player)
+			}
+			for _, player := range diffGuildPlayers(old.Players, g.Players) {
+				event(database.GuildEventLeave, g, player)
+			}
+		}
+
+		// 没有匹配到的公会已解散，移到归档中
+		archive := tx.Bucket([]byte("guild_archive"))
+		for key, old := range stored {
+			if matched[key] {
+				continue
+			}
+			v, err := json.Marshal(database.ArchivedGuild{Guild: old, ArchivedAt: now})
+			if err != nil {
+				return err
+			}
+			if err := archive.Put([]byte(now.UTC().Format(historyKeyLayout)+"_"+key), v); err != nil {
+				return err
+			}
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+			event(database.GuildEventDisband, old, nil)
+		}
+
+		for _, key := range moved {
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+
+		// 遍历guilds数组
+		for _, g := range guilds {
+			// 将guild对象序列化为JSON格式的字节数组
+			v, err := json.Marshal(g)
+			if err != nil {
+				// 如果序列化出错，则返回错误
+				return err
+			}
+			// 将序列化后的字节数组存入bucket中，键为guild的AdminPlayerUid
+			if err := b.Put([]byte(g.AdminPlayerUid), v); err != nil {
+				// 如果存入bucket出错，则返回错误
+				return err
+			}
+		}
+
+		history := tx.Bucket([]byte("guild_history"))
+		for _, e := range events {
+			// 使用自增序号作为键，保证按写入顺序排列
+			id, err := history.NextSequence()
+			if err != nil {
+				return err
+			}
+			v, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, id)
+			if err := history.Put(key, v); err != nil {
+				return err
+			}
+		}
+		// 所有操作成功完成，返回nil
+		return nil
+	})
+}
+
+// diffGuildPlayers 返回在 a 中但不在 b 中的玩家
+func diffGuildPlayers(a, b []*database.GuildPlayer) []*database.GuildPlayer {
+	uids := make(map[string]bool, len(b))
+	for _, player := range b {
+		uids[player.PlayerUid] = true
+	}
+	diff := make([]*database.GuildPlayer, 0)
+	for _, player := range a {
+		if !uids[player.PlayerUid] {
+			diff = append(diff, player)
+		}
+	}
+	return diff
+}
+
+func adminPlayer(g database.Guild) *database.GuildPlayer {
+	for _, player := range g.Players {
+		if player.PlayerUid == g.AdminPlayerUid {
+			return player
+		}
+	}
+	return &database.GuildPlayer{PlayerUid: g.AdminPlayerUid}
+}
+
 func ListGuilds(db *bbolt.DB) ([]database.Guild, error) {
 	// 初始化一个空的 Guild 切片
 	guilds := make([]database.Guild, 0)
@@ -93,3 +255,100 @@ func GetGuild(db *bbolt.DB, playerUID string) (database.Guild, error) {
 	}
 	return guild, nil
 }
+
+type GuildEventFilter struct {
+	StartTime time.Time
+	EndTime   time.Time
+	// 只返回指定公会的事件，GroupId 为空时按管理员匹配
+	GroupId        string
+	AdminPlayerUid string
+}
+
+func (f GuildEventFilter) match(e database.GuildEvent) bool {
+	if !f.StartTime.IsZero() && e.Time.Before(f.StartTime) {
+		return false
+	}
+	if !f.EndTime.IsZero() && e.Time.After(f.EndTime) {
+		return false
+	}
+	if f.GroupId != "" {
+		return e.GroupId == f.GroupId
+	}
+	if f.AdminPlayerUid != "" {
+		return e.AdminPlayerUid == f.AdminPlayerUid
+	}
+	return true
+}
+
+// ListGuildEvents 返回符合条件的公会历史，按时间倒序排列
+func ListGuildEvents(db *bbolt.DB, filter GuildEventFilter) ([]database.GuildEvent, error) {
+	events := make([]database.GuildEvent, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte("guild_history")).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var e database.GuildEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if filter.match(e) {
+				events = append(events, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListArchivedGuilds 返回已解散的公会，最近解散的在前
+func ListArchivedGuilds(db *bbolt.DB) ([]database.ArchivedGuild, error) {
+	guilds := make([]database.ArchivedGuild, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte("guild_archive")).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var guild database.ArchivedGuild
+			if err := json.Unmarshal(v, &guild); err != nil {
+				return err
+			}
+			guilds = append(guilds, guild)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return guilds, nil
+}
+
+// GetGuildStats 汇总公会成员的等级、帕鲁数量以及据点信息
+func GetGuildStats(db *bbolt.DB, guild database.Guild) (database.GuildStats, error) {
+	stats := database.GuildStats{
+		AdminPlayerUid: guild.AdminPlayerUid,
+		Name:           guild.Name,
+		MemberCount:    len(guild.Players),
+		BaseCampCount:  len(guild.BaseCamp),
+		BaseCampLevel:  guild.BaseCampLevel,
+	}
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("players"))
+		for _, member := range guild.Players {
+			v := b.Get([]byte(member.PlayerUid))
+			if v == nil {
+				continue
+			}
+			var player database.Player
+			if err := json.Unmarshal(v, &player); err != nil {
+				return err
+			}
+			stats.TotalLevel += int64(player.Level)
+			stats.PalCount += len(player.Pals)
+		}
+		return nil
+	})
+	if err != nil {
+		return database.GuildStats{}, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+// 历史记录的键为 UTC 时间，定长格式保证按字典序即按时间排序
+const historyKeyLayout = "2006-01-02T15:04:05.000Z"
+
+// AddPlayerSnapshots 记录玩家快照，与上一条快照相比没有变化的玩家不再记录，最后在线时间变化时仍然记录
+func AddPlayerSnapshots(db *bbolt.DB, players []database.Player, saveTime time.Time) error {
+	key := []byte(saveTime.UTC().Format(historyKeyLayout))
+	return db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为 "player_history" 的 bucket
+		b := tx.Bucket([]byte("player_history"))
+		for _, p := range players {
+			// 每个玩家使用单独的子 bucket 保存历史记录
+			pb, err := b.CreateBucketIfNotExists([]byte(p.PlayerUid))
+			if err != nil {
+				return err
+			}
+			snapshot := database.PlayerSnapshot{
+				PlayerUid:   p.PlayerUid,
+				Nickname:    p.Nickname,
+				Level:       p.Level,
+				Exp:         p.Exp,
+				PalCount:    len(p.Pals),
+				StatusPoint: p.StatusPoint,
+				LastOnline:  p.LastOnline,
+				SaveTime:    saveTime,
+			}
+			// 存档中的最后在线时间优先
+			if p.SaveLastOnline != "" {
+				if parsedTime, err := time.Parse(time.RFC3339, p.SaveLastOnline); err == nil {
+					snapshot.LastOnline = parsedTime
+				}
+			}
+			if _, last := pb.Cursor().Last(); last != nil {
+				var prev database.PlayerSnapshot
+				if err := json.Unmarshal(last, &prev); err == nil && sameSnapshot(prev, snapshot) {
+					continue
+				}
+			}
+			v, err := json.Marshal(snapshot)
+			if err != nil {
+				return err
+			}
+			if err := pb.Put(key, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sameSnapshot 比较两条快照中除保存时间以外的字段
+func sameSnapshot(a, b database.PlayerSnapshot) bool {
+	return a.Nickname == b.Nickname && a.Level == b.Level && a.Exp == b.Exp &&
+		a.PalCount == b.PalCount && maps.Equal(a.StatusPoint, b.StatusPoint) &&
+		a.LastOnline.Equal(b.LastOnline)
+}
+
+// CleanPlayerHistory 删除 before 之前的玩家快照。没有变化的玩家不会记录新的快照，
+// 因此每个玩家都保留最后一条快照，用于判断之后的快照是否有变化
+func CleanPlayerHistory(db *bbolt.DB, before time.Time) error {
+	end := []byte(before.UTC().Format(historyKeyLayout))
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("player_history"))
+		return b.ForEachBucket(func(uid []byte) error {
+			c := b.Bucket(uid).Cursor()
+			lastKey, _ := c.Last()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0 && !bytes.Equal(k, lastKey); k, _ = c.First() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func ListPlayerHistory(db *bbolt.DB, playerUid string, startTime, endTime time.Time) ([]database.PlayerSnapshot, error) {
+	snapshots := make([]database.PlayerSnapshot, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		pb := tx.Bucket([]byte("player_history")).Bucket([]byte(playerUid))
+		if pb == nil {
+			return ErrNoRecord
+		}
+		c := pb.Cursor()
+		// 从开始时间处定位，按时间顺序遍历到结束时间
+		var k, v []byte
+		if startTime.IsZero() {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek([]byte(startTime.UTC().Format(historyKeyLayout)))
+		}
+		for ; k != nil; k, v = c.Next() {
+			var snapshot database.PlayerSnapshot
+			if err := json.Unmarshal(v, &snapshot); err != nil {
+				return err
+			}
+			if !endTime.IsZero() && snapshot.SaveTime.After(endTime) {
+				break
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+var ErrNoSteamId = errors.New("steam_id 不能为空")
+
+// 全局白名单和全局封禁保存在默认服务器的数据库中，按 SteamId 存储，对所有服务器生效
+
+func AddGlobalWhitelist(db *bbolt.DB, player database.PlayerW) error {
+	if player.SteamID == "" {
+		return ErrNoSteamId
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("global_whitelist"))
+		v, err := json.Marshal(player)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(player.SteamID), v)
+	})
+}
+
+func ListGlobalWhitelist(db *bbolt.DB) ([]database.PlayerW, error) {
+	players := make([]database.PlayerW, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("global_whitelist"))
+		return b.ForEach(func(k, v []byte) error {
+			var player database.PlayerW
+			if err := json.Unmarshal(v, &player); err != nil {
+				return err
+			}
+			players = append(players, player)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return players, nil
+}
+
+func RemoveGlobalWhitelist(db *bbolt.DB, steamId string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("global_whitelist"))
+		if b.Get([]byte(steamId)) == nil {
+			return ErrNoRecord
+		}
+		return b.Delete([]byte(steamId))
+	})
+}
+
+func PutGlobalBan(db *bbolt.DB, ban database.Ban) error {
+	if ban.SteamId == "" {
+		return ErrNoSteamId
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("global_bans"))
+		v, err := json.Marshal(ban)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(ban.SteamId), v)
+	})
+}
+
+func GetGlobalBan(db *bbolt.DB, steamId string) (database.Ban, error) {
+	var ban database.Ban
+	err := db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket([]byte("global_bans")).Get([]byte(steamId))
+		if v == nil {
+			return ErrNoRecord
+		}
+		return json.Unmarshal(v, &ban)
+	})
+	if err != nil {
+		return database.Ban{}, err
+	}
+	return ban, nil
+}
+
+func DeleteGlobalBan(db *bbolt.DB, steamId string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("global_bans")).Delete([]byte(steamId))
+	})
+}
+
+// ListGlobalBans 返回全部全局封禁，按封禁时间倒序排列
+func ListGlobalBans(db *bbolt.DB) ([]database.Ban, error) {
+	bans := make([]database.Ban, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("global_bans"))
+		return b.ForEach(func(k, v []byte) error {
+			var ban database.Ban
+			if err := json.Unmarshal(v, &ban); err != nil {
+				return err
+			}
+			bans = append(bans, ban)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].CreatedAt.After(bans[j].CreatedAt)
+	})
+	return bans, nil
+}
+
+// ServerPlayers 一个服务器中的玩家记录
+type ServerPlayers struct {
+	Server  string
+	Players []database.TersePlayer
+}
+
+// LinkIdentities 按 SteamId 关联各个服务器中的玩家记录，没有有效 SteamId 的玩家会被忽略，
+// 结果按最后在线时间倒序排列
+func LinkIdentities(servers []ServerPlayers) []database.Identity {
+	identities := make(map[string]*database.Identity)
+	for _, server := range servers {
+		for _, player := range server.Players {
+			if player.SteamId == "" || strings.Contains(player.SteamId, "000000") {
+				continue
+			}
+			identity, ok := identities[player.SteamId]
+			if !ok {
+				identity = &database.Identity{SteamId: player.SteamId, Players: make([]database.IdentityPlayer, 0, 1)}
+				identities[player.SteamId] = identity
+			}
+			identity.Players = append(identity.Players, database.IdentityPlayer{
+				Server:     server.Server,
+				PlayerUid:  player.PlayerUid,
+				Nickname:   player.Nickname,
+				Level:      player.Level,
+				LastOnline: player.LastOnline,
+			})
+			if identity.Nickname == "" || player.LastOnline.After(identity.LastOnline) {
+				identity.Nickname = player.Nickname
+				identity.LastOnline = player.LastOnline
+			}
+		}
+	}
+
+	result := make([]database.Identity, 0, len(identities))
+	for _, identity := range identities {
+		result = append(result, *identity)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastOnline.After(result[j].LastOnline)
+	})
+	return result
+}
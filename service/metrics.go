@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	MetricsResolutionRaw  = "raw"
+	MetricsResolutionHour = "hour"
+)
+
+// metricsKey 使用毫秒时间戳作为键，保证按时间顺序排列
+func metricsKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixMilli()))
+	return key
+}
+
+// scanMetrics 按时间顺序遍历 [start, end) 范围内的指标，start 和 end 为零时不限制
+func scanMetrics(b *bbolt.Bucket, start, end time.Time, fn func(k []byte, point database.MetricsPoint) error) error {
+	c := b.Cursor()
+	var k, v []byte
+	if start.IsZero() {
+		k, v = c.First()
+	} else {
+		k, v = c.Seek(metricsKey(start))
+	}
+	for ; k != nil; k, v = c.Next() {
+		var point database.MetricsPoint
+		if err := json.Unmarshal(v, &point); err != nil {
+			return err
+		}
+		if !end.IsZero() && !point.Time.Before(end) {
+			break
+		}
+		if err := fn(k, point); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeMetricsPoint 将较新的 b 合并到 a 中
+func mergeMetricsPoint(a *database.MetricsPoint, b database.MetricsPoint) {
+	online := a.OnlineSamples + b.OnlineSamples
+	if b.OnlineSamples > 0 {
+		if a.OnlineSamples == 0 || b.MinServerFps < a.MinServerFps {
+			a.MinServerFps = b.MinServerFps
+		}
+		a.ServerFps = (a.ServerFps*float64(a.OnlineSamples) + b.ServerFps*float64(b.OnlineSamples)) / float64(online)
+		a.ServerFrameTime = (a.ServerFrameTime*float64(a.OnlineSamples) + b.ServerFrameTime*float64(b.OnlineSamples)) / float64(online)
+		a.Uptime = b.Uptime
+	}
+	if b.CurrentPlayerNum > a.CurrentPlayerNum {
+		a.CurrentPlayerNum = b.CurrentPlayerNum
+	}
+	a.Samples += b.Samples
+	a.OnlineSamples = online
+}
+
+// aggregateHourly 将指标按小时合并
+func aggregateHourly(hours map[int64]*database.MetricsPoint, point database.MetricsPoint) {
+	hour := point.Time.Truncate(time.Hour)
+	if p, ok := hours[hour.UnixMilli()]; ok {
+		mergeMetricsPoint(p, point)
+		return
+	}
+	point.Time = hour
+	hours[hour.UnixMilli()] = &point
+}
+
+func AddMetricsPoint(db *bbolt.DB, point database.MetricsPoint) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为 "metrics" 的 bucket
+		b := tx.Bucket([]byte("metrics"))
+		v, err := json.Marshal(point)
+		if err != nil {
+			return err
+		}
+		return b.Put(metricsKey(point.Time), v)
+	})
+}
+
+// ListMetrics 按时间顺序返回指标，resolution 为 hour 时返回每小时的汇总
+func ListMetrics(db *bbolt.DB, resolution string, start, end time.Time) ([]database.MetricsPoint, error) {
+	points := make([]database.MetricsPoint, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		if resolution != MetricsResolutionHour {
+			return scanMetrics(tx.Bucket([]byte("metrics")), start, end, func(k []byte, point database.MetricsPoint) error {
+				points = append(points, point)
+				return nil
+			})
+		}
+
+		// 尚未降采样的原始数据也按小时汇总
+		hours := make(map[int64]*database.MetricsPoint)
+		if !start.IsZero() {
+			start = start.Truncate(time.Hour)
+		}
+		err := scanMetrics(tx.Bucket([]byte("metrics_hourly")), start, end, func(k []byte, point database.MetricsPoint) error {
+			aggregateHourly(hours, point)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = scanMetrics(tx.Bucket([]byte("metrics")), start, end, func(k []byte, point database.MetricsPoint) error {
+			aggregateHourly(hours, point)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, point := range hours {
+			points = append(points, *point)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+	return points, nil
+}
+
+// DownsampleMetrics 将 before 之前的原始数据按小时汇总，并删除原始数据
+func DownsampleMetrics(db *bbolt.DB, before time.Time) error {
+	before = before.Truncate(time.Hour)
+	return db.Update(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte("metrics"))
+		hourly := tx.Bucket([]byte("metrics_hourly"))
+
+		hours := make(map[int64]*database.MetricsPoint)
+		keys := make([][]byte, 0)
+		err := scanMetrics(raw, time.Time{}, before, func(k []byte, point database.MetricsPoint) error {
+			aggregateHourly(hours, point)
+			keys = append(keys, append([]byte(nil), k...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, point := range hours {
+			// 与已经汇总的同一小时的数据合并
+			if v := hourly.Get(metricsKey(point.Time)); v != nil {
+				var existing database.MetricsPoint
+				if err := json.Unmarshal(v, &existing); err != nil {
+					return err
+				}
+				mergeMetricsPoint(&existing, *point)
+				point = &existing
+			}
+			v, err := json.Marshal(point)
+			if err != nil {
+				return err
+			}
+			if err = hourly.Put(metricsKey(point.Time), v); err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			if err := raw.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CleanMetrics 删除 before 之前的所有指标
+func CleanMetrics(db *bbolt.DB, before time.Time) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		end := metricsKey(before)
+		for _, name := range []string{"metrics", "metrics_hourly"} {
+			c := tx.Bucket([]byte(name)).Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.First() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
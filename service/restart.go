@@ -0,0 +1,44 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+// 计划中的重启在 "restart" 桶中的键
+var plannedRestartKey = []byte("planned")
+
+func GetPlannedRestart(db *bbolt.DB) (database.PlannedRestart, error) {
+	var restart database.PlannedRestart
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("restart"))
+		v := b.Get(plannedRestartKey)
+		if v == nil {
+			return ErrNoRecord
+		}
+		return json.Unmarshal(v, &restart)
+	})
+	if err != nil {
+		return database.PlannedRestart{}, err
+	}
+	return restart, nil
+}
+
+func PutPlannedRestart(db *bbolt.DB, restart database.PlannedRestart) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("restart"))
+		v, err := json.Marshal(restart)
+		if err != nil {
+			return err
+		}
+		return b.Put(plannedRestartKey, v)
+	})
+}
+
+func DeletePlannedRestart(db *bbolt.DB) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("restart")).Delete(plannedRestartKey)
+	})
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+// SyncSessions 根据当前在线玩家更新会话记录，返回本次新加入和离开的玩家会话。
+// 未结束的会话保存在数据库中，重启后可以继续；若会话超过 timeout 未被更新，
+// 则认为期间已离线，以最后一次在线时间结束该会话。
+func SyncSessions(db *bbolt.DB, players []database.OnlinePlayer, now time.Time, timeout time.Duration) ([]database.PlayerSession, []database.PlayerSession, error) {
+	joined := make([]database.PlayerSession, 0)
+	left := make([]database.PlayerSession, 0)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为 "sessions" 的 bucket
+		b := tx.Bucket([]byte("sessions"))
+
+		online := make(map[string]database.OnlinePlayer, len(players))
+		for _, p := range players {
+			if p.PlayerUid != "" {
+				online[p.PlayerUid] = p
+			}
+		}
+
+		// 每个玩家只有最后一条会话可能未结束
+		updated := make([]database.PlayerSession, 0)
+		err := b.ForEachBucket(func(uid []byte) error {
+			k, v := b.Bucket(uid).Cursor().Last()
+			if k == nil {
+				return nil
+			}
+			var session database.PlayerSession
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			if !session.EndTime.IsZero() {
+				return nil
+			}
+
+			p, ok := online[session.PlayerUid]
+			stale := timeout > 0 && now.Sub(session.LastSeen) > timeout
+			switch {
+			case stale:
+				session.EndTime = session.LastSeen
+			case !ok:
+				session.EndTime = now
+				left = append(left, session)
+			default:
+				// 玩家仍在线，只更新最后在线时间
+				delete(online, session.PlayerUid)
+				session.LastSeen = now
+				session.Ip = p.Ip
+			}
+			updated = append(updated, session)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, session := range updated {
+			if err := putSession(b.Bucket([]byte(session.PlayerUid)), session); err != nil {
+				return err
+			}
+		}
+
+		// 为新加入的玩家创建会话
+		for uid, p := range online {
+			pb, err := b.CreateBucketIfNotExists([]byte(uid))
+			if err != nil {
+				return err
+			}
+			session := database.PlayerSession{
+				PlayerUid: p.PlayerUid,
+				Nickname:  p.Nickname,
+				SteamId:   p.SteamId,
+				Ip:        p.Ip,
+				StartTime: now,
+				LastSeen:  now,
+			}
+			if err := putSession(pb, session); err != nil {
+				return err
+			}
+			joined = append(joined, session)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return joined, left, nil
+}
+
+// CloseStaleSessions 结束超过 timeout 未被更新的会话，以最后一次在线时间作为结束时间。
+// 无法获取在线玩家时调用，避免服务器长时间无法访问期间玩家一直显示为在线
+func CloseStaleSessions(db *bbolt.DB, now time.Time, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("sessions"))
+		return b.ForEachBucket(func(uid []byte) error {
+			pb := b.Bucket(uid)
+			k, v := pb.Cursor().Last()
+			if k == nil {
+				return nil
+			}
+			var session database.PlayerSession
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			if !session.EndTime.IsZero() || now.Sub(session.LastSeen) <= timeout {
+				return nil
+			}
+			session.EndTime = session.LastSeen
+			return putSession(pb, session)
+		})
+	})
+}
+
+func putSession(pb *bbolt.Bucket, session database.PlayerSession) error {
+	v, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return pb.Put([]byte(session.StartTime.UTC().Format(historyKeyLayout)), v)
+}
+
+func ListPlayerSessions(db *bbolt.DB, playerUid string, startTime, endTime time.Time) ([]database.PlayerSession, error) {
+	sessions := make([]database.PlayerSession, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		pb := tx.Bucket([]byte("sessions")).Bucket([]byte(playerUid))
+		if pb == nil {
+			return ErrNoRecord
+		}
+		return pb.ForEach(func(k, v []byte) error {
+			var session database.PlayerSession
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			// 筛选与时间范围有交集的会话
+			if !startTime.IsZero() && !session.EndTime.IsZero() && session.EndTime.Before(startTime) {
+				return nil
+			}
+			if !endTime.IsZero() && session.StartTime.After(endTime) {
+				return nil
+			}
+			sessions = append(sessions, session)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// ListPlaytime 统计每个玩家的总游戏时长和最近 7 天的游戏时长，按总时长降序排列。
+// 未结束但超过 timeout 未被更新的会话不视为在线
+func ListPlaytime(db *bbolt.DB, now time.Time, timeout time.Duration) ([]database.PlayerPlaytime, error) {
+	weekStart := now.AddDate(0, 0, -7)
+	playtimes := make([]database.PlayerPlaytime, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("sessions"))
+		return b.ForEachBucket(func(uid []byte) error {
+			playtime := database.PlayerPlaytime{PlayerUid: string(uid)}
+			err := b.Bucket(uid).ForEach(func(k, v []byte) error {
+				var session database.PlayerSession
+				if err := json.Unmarshal(v, &session); err != nil {
+					return err
+				}
+				// 未结束的会话计算到最后一次在线时间
+				end := session.EndTime
+				if end.IsZero() {
+					end = session.LastSeen
+				}
+				playtime.Nickname = session.Nickname
+				playtime.Online = session.EndTime.IsZero() && (timeout <= 0 || now.Sub(session.LastSeen) <= timeout)
+				playtime.Sessions++
+				playtime.TotalSeconds += int64(end.Sub(session.StartTime).Seconds())
+				// 只计算与最近 7 天重叠的部分
+				if end.After(weekStart) {
+					start := session.StartTime
+					if start.Before(weekStart) {
+						start = weekStart
+					}
+					playtime.WeeklySeconds += int64(end.Sub(start).Seconds())
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			playtimes = append(playtimes, playtime)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(playtimes, func(i, j int) bool {
+		return playtimes[i].TotalSeconds > playtimes[j].TotalSeconds
+	})
+	return playtimes, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+var ErrUserExists = errors.New("用户已存在")
+
+func AddUser(db *bbolt.DB, user database.User) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为 "users" 的 bucket
+		b := tx.Bucket([]byte("users"))
+		if b.Get([]byte(user.Username)) != nil {
+			return ErrUserExists
+		}
+		v, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(user.Username), v)
+	})
+}
+
+func PutUser(db *bbolt.DB, user database.User) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("users"))
+		if b.Get([]byte(user.Username)) == nil {
+			return ErrNoRecord
+		}
+		v, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(user.Username), v)
+	})
+}
+
+func GetUser(db *bbolt.DB, username string) (database.User, error) {
+	var user database.User
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("users"))
+		v := b.Get([]byte(username))
+		if v == nil {
+			return ErrNoRecord
+		}
+		return json.Unmarshal(v, &user)
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+func ListUsers(db *bbolt.DB) ([]database.TerseUser, error) {
+	users := make([]database.TerseUser, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("users"))
+		return b.ForEach(func(k, v []byte) error {
+			// 只返回不含密码哈希的用户信息
+			var user database.TerseUser
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func DeleteUser(db *bbolt.DB, username string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("users"))
+		if b.Get([]byte(username)) == nil {
+			return ErrNoRecord
+		}
+		return b.Delete([]byte(username))
+	})
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/qycnet/palworld-server-tool-main/internal/database"
+	"go.etcd.io/bbolt"
+)
+
+// 只保留最近的投递记录
+const webhookDeliveryLimit = 1000
+
+func AddWebhookDelivery(db *bbolt.DB, delivery database.WebhookDelivery) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		// 获取名为 "webhook_deliveries" 的 bucket
+		b := tx.Bucket([]byte("webhook_deliveries"))
+		// 使用自增序号作为键，保证按写入顺序排列
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(delivery)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+		if err = b.Put(key, v); err != nil {
+			return err
+		}
+
+		// 删除序号早于最近 webhookDeliveryLimit 条的记录
+		if id <= webhookDeliveryLimit {
+			return nil
+		}
+		min := make([]byte, 8)
+		binary.BigEndian.PutUint64(min, id-webhookDeliveryLimit+1)
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, min) < 0; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListWebhookDeliveries 返回投递记录，按时间倒序排列，webhook 和 event 为空时不筛选
+func ListWebhookDeliveries(db *bbolt.DB, webhook, event string) ([]database.WebhookDelivery, error) {
+	deliveries := make([]database.WebhookDelivery, 0)
+	err := db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte("webhook_deliveries")).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var delivery database.WebhookDelivery
+			if err := json.Unmarshal(v, &delivery); err != nil {
+				return err
+			}
+			if (webhook == "" || delivery.Webhook == webhook) && (event == "" || delivery.Event == event) {
+				deliveries = append(deliveries, delivery)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}